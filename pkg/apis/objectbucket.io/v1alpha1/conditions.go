@@ -0,0 +1,57 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// Condition types set by the controller on the status of ObjectBucketClaims and ObjectBuckets.
+// Conditions are machine-readable companions to the Phase field and are meant for tooling (e.g.
+// health checks) which needs to know why a resource is in a given phase.
+const (
+	// ConditionProvisioned is true when the provisioner has created, or granted access to, the bucket.
+	ConditionProvisioned = "Provisioned"
+	// ConditionCredentialsReady is true when the Secret containing the bucket credentials exists.
+	ConditionCredentialsReady = "CredentialsReady"
+	// ConditionEndpointReady is true when the ConfigMap containing the bucket endpoint exists.
+	ConditionEndpointReady = "EndpointReady"
+	// ConditionConfigApplied is true when the claim's additionalConfig has been applied to a bound
+	// bucket by a provisioner implementing the optional Updater interface.
+	ConditionConfigApplied = "ConfigApplied"
+	// ConditionDeletionBlocked is true when the claim has been deleted but the bucket or its
+	// generated resources could not be cleaned up.
+	ConditionDeletionBlocked = "DeletionBlocked"
+)
+
+// Condition reasons set by the controller. Reasons are CamelCase and describe the most recent
+// transition of a condition.
+const (
+	ReasonProvisioning       = "Provisioning"
+	ReasonProvisioned        = "Provisioned"
+	ReasonProvisioningFailed = "ProvisioningFailed"
+	ReasonSecretCreated      = "SecretCreated"
+	ReasonSecretFailed       = "SecretFailed"
+	ReasonRefreshed          = "CredentialsRefreshed"
+	ReasonRefreshFailed      = "CredentialsRefreshFailed"
+	ReasonRotated            = "CredentialsRotated"
+	ReasonRotationFailed     = "CredentialsRotationFailed"
+	ReasonConfigMapCreated   = "ConfigMapCreated"
+	ReasonConfigMapFailed    = "ConfigMapFailed"
+	ReasonUpdated            = "Updated"
+	ReasonUpdateFailed       = "UpdateFailed"
+	ReasonDeleteFailed       = "DeleteFailed"
+	ReasonRevokeFailed       = "RevokeFailed"
+	ReasonReclaimFailed      = "ReclaimFailed"
+	ReasonReleaseFailed      = "ReleaseFailed"
+)
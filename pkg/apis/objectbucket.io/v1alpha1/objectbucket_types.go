@@ -40,7 +40,54 @@ type mapper interface {
 const (
 	AwsKeyField        = "AWS_ACCESS_KEY_ID"
 	AwsSecretField     = "AWS_SECRET_ACCESS_KEY"
+	AwsSessionField    = "AWS_SESSION_TOKEN"
 	StorageClassBucket = "bucketName"
+	// StorageClassRotationInterval is the storage class parameter setting the interval, in Go
+	// duration syntax (e.g. "720h"), at which bucket credentials are rotated.
+	StorageClassRotationInterval = "credentialRotationInterval"
+	// StorageClassRotationOverlap is the storage class parameter setting how long, in Go duration
+	// syntax, the previous credentials stay valid after a rotation. Defaults to one hour.
+	StorageClassRotationOverlap = "credentialRotationOverlap"
+	// StorageClassSecretName and StorageClassConfigMapName are the storage class parameters
+	// naming the Secret and ConfigMap of claims which do not name them. The values are Go templates
+	// executed on the claim, e.g. "{{ .Name }}-bucket".
+	StorageClassSecretName    = "generatedSecretName"
+	StorageClassConfigMapName = "generatedConfigMapName"
+	// StorageClassSecretKeyMapping and StorageClassConfigMapKeyMapping are the storage class
+	// parameters renaming the keys written to the Secret and ConfigMap, as comma separated pairs,
+	// e.g. "BUCKET_URL=AWS_ENDPOINT_URL,BUCKET_REGION=AWS_REGION".
+	StorageClassSecretKeyMapping    = "secretKeyMapping"
+	StorageClassConfigMapKeyMapping = "configMapKeyMapping"
+	// StorageClassConnectionFormats is the storage class parameter listing, comma separated, the
+	// connection files written to the Secret, e.g. "aws-credentials,aws-config". See package
+	// connection for the supported formats.
+	StorageClassConnectionFormats = "connectionFormats"
+	// StorageClassServiceBinding is the storage class parameter which, when "true", has a
+	// servicebinding.io binding Secret written for each claim, in addition to its Secret.
+	StorageClassServiceBinding = "serviceBinding"
+	// StorageClassReclaimPolicy is the storage class parameter setting the reclaim policy of new
+	// buckets, overriding the class's reclaimPolicy. Unlike the latter, it accepts the library
+	// defined reclaim policies, e.g. "Erase".
+	StorageClassReclaimPolicy = "reclaimPolicy"
+	// RotateCredentialsAnnotation requests the rotation of a claim's credentials when set to a
+	// value, e.g. a timestamp, which differs from the value of the last handled request.
+	RotateCredentialsAnnotation = "objectbucket.io/rotate-credentials"
+)
+
+// Reclaim policies defined by the library, in addition to the PersistentVolumeReclaimPolicy values
+// Delete and Retain. They apply to new buckets only, access to existing buckets is always revoked,
+// and are carried out by provisioners implementing the matching optional interface of package api.
+const (
+	// ReclaimPolicyErase empties the bucket of a deleted claim and revokes access to it, keeping
+	// the bucket.
+	ReclaimPolicyErase corev1.PersistentVolumeReclaimPolicy = "Erase"
+	// ReclaimPolicySuspend disables access to the bucket of a deleted claim while keeping its
+	// credentials, so that access may be restored later. The bucket's OB and Secret are kept after
+	// the claim is deleted.
+	ReclaimPolicySuspend corev1.PersistentVolumeReclaimPolicy = "Suspend"
+	// ReclaimPolicyArchive moves the bucket of a deleted claim to cold storage, or tags it for
+	// later deletion, and revokes access to it.
+	ReclaimPolicyArchive corev1.PersistentVolumeReclaimPolicy = "Archive"
 )
 
 // AccessKeys is an Authentication type for passing AWS S3 style key pairs from the provisioner to the reconciler
@@ -49,46 +96,102 @@ type AccessKeys struct {
 	AccessKeyID string `json:"-"`
 	// SecretAccessKey is the S3 style secret key to be written to a secret
 	SecretAccessKey string `json:"-"`
+	// SessionToken is the S3 style session token of temporary credentials, written to a secret if set
+	SessionToken string `json:"-"`
 }
 
 var _ mapper = &AccessKeys{}
 
 func (ak *AccessKeys) toMap() map[string]string {
-	return map[string]string{
+	m := map[string]string{
 		AwsKeyField:    ak.AccessKeyID,
 		AwsSecretField: ak.SecretAccessKey,
 	}
+	if ak.SessionToken != "" {
+		m[AwsSessionField] = ak.SessionToken
+	}
+	return m
 }
 
 // Authentication wraps all supported auth types.  The design choice enables expansion of supported types while
 // protecting backwards compatibility.
 type Authentication struct {
-	AccessKeys           *AccessKeys       `json:"-"`
+	AccessKeys *AccessKeys `json:"-"`
+	// AdditionalSecretData is written to the claim's Secret alongside the keys of the auth type. Its keys must not
+	// collide with the keys written for any auth type, e.g. AWS_ACCESS_KEY_ID.
 	AdditionalSecretData map[string]string `json:"-"`
+	// ExpirationTime is set for temporary credentials. The library refreshes such credentials before they expire.
+	ExpirationTime *metav1.Time `json:"-"`
 }
 
-// ToMap converts the any defined authentication type into a map[string]string for writing to a Secret.StringData field
+// ToMap converts the any defined authentication type, along with AdditionalSecretData, into a map[string]string for
+// writing to a Secret.StringData field. The keys of the authentication type take precedence over AdditionalSecretData.
 func (a *Authentication) ToMap() map[string]string {
+	m := map[string]string{}
 	if a == nil {
-		return map[string]string{}
+		return m
+	}
+	for k, v := range a.AdditionalSecretData {
+		m[k] = v
 	}
 	if a.AccessKeys != nil {
-		return a.AccessKeys.toMap()
+		for k, v := range a.AccessKeys.toMap() {
+			m[k] = v
+		}
 	}
-	return map[string]string{}
+	return m
 }
 
 // Endpoint contains all connection relevant data that an app may require for accessing
 // the bucket
 type Endpoint struct {
-	BucketHost           string            `json:"bucketHost"`
-	BucketPort           int               `json:"bucketPort"`
-	BucketName           string            `json:"bucketName"`
-	Region               string            `json:"region"`
-	SubRegion            string            `json:"subRegion"`
+	BucketHost string `json:"bucketHost"`
+	BucketPort int    `json:"bucketPort"`
+	BucketName string `json:"bucketName"`
+	Region     string `json:"region"`
+	SubRegion  string `json:"subRegion"`
+	// Scheme is the URL scheme of the endpoint, either http or https. If empty, the scheme prefixed to BucketHost is
+	// used, if any, otherwise the endpoint has no URL.
+	Scheme string `json:"scheme,omitempty"`
+	// URL is the full URL of the endpoint, e.g. https://s3.example.com:8443/prefix. If empty, it is composed from
+	// Scheme, BucketHost and BucketPort.
+	URL string `json:"url,omitempty"`
+	// TLS holds the TLS settings a client needs to connect to the endpoint.
+	TLS *EndpointTLS `json:"tls,omitempty"`
+	// AddressingStyle tells clients whether the bucket name is part of the request path or of the host name.
+	AddressingStyle AddressingStyle `json:"addressingStyle,omitempty"`
+	// AdditionalConfigData is written to the claim's ConfigMap alongside the endpoint fields. Its keys must not
+	// collide with the keys written for the endpoint fields, e.g. BUCKET_HOST.
 	AdditionalConfigData map[string]string `json:"additionalConfig"`
 }
 
+// EndpointTLS contains the TLS settings of an Endpoint.
+type EndpointTLS struct {
+	// InsecureSkipVerify tells clients not to verify the certificate presented by the endpoint.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// CABundle is a PEM encoded bundle of the certificate authorities to trust when connecting to the endpoint.
+	CABundle string `json:"caBundle,omitempty"`
+	// CABundleRef references a ConfigMap key holding the CA bundle. It is only read if CABundle is empty.
+	CABundleRef *CABundleReference `json:"caBundleRef,omitempty"`
+}
+
+// CABundleReference references a key of a ConfigMap holding a PEM encoded CA bundle.
+type CABundleReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+}
+
+// AddressingStyle determines how the bucket is addressed in requests to the endpoint.
+type AddressingStyle string
+
+const (
+	// AddressingStylePath puts the bucket name into the request path, e.g. https://s3.example.com/bucket.
+	AddressingStylePath AddressingStyle = "path"
+	// AddressingStyleVirtual puts the bucket name into the host name, e.g. https://bucket.s3.example.com.
+	AddressingStyleVirtual AddressingStyle = "virtual"
+)
+
 // Connection encapsulates Endpoint and Authentication data to simplify the expected return values of the Provision()
 // interface method.  This makes it more clear to library consumers what specific values they should return from their
 // Provisioner interface implementation.
@@ -119,15 +222,62 @@ const (
 	// this phase can occur when the claim is deleted and the reconciler is in the process of either deleting the bucket or
 	// revoking access to that bucket in the case of brownfield.
 	ObjectBucketStatusPhaseReleased ObjectBucketStatusPhase = "Released"
-	// ObjectBucketStatusPhaseFailed TODO this phase does not have a defined reason for existing.  If provisioning fails
-	//  the OB is cleaned up.  Since we generate OBs for brownfield cases, we also would delete them on failures.  The
-	//  result is that if this phase is set, the OB would deleted soon after anyway.
+	// ObjectBucketStatusPhaseFailed indicates that the provisioner returned a permanent error while deleting the bucket
+	// or revoking access to it.  Cleanup is not retried and the OB, along with its claim, remain until an administrator
+	// intervenes.
 	ObjectBucketStatusPhaseFailed ObjectBucketStatusPhase = "Failed"
 )
 
 // ObjectBucketStatus defines the observed state of ObjectBucket
 type ObjectBucketStatus struct {
 	Phase ObjectBucketStatusPhase `json:"phase"`
+
+	// ObservedGeneration is the most recent generation of the bucket observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions are the latest available observations of the bucket's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// AppliedAdditionalConfig is the additionalConfig of the claim last applied to the bucket by
+	// Provision, Grant or Update. A differing additionalConfig on a bound claim triggers Update.
+	// +optional
+	AppliedAdditionalConfig map[string]string `json:"appliedAdditionalConfig,omitempty"`
+
+	// Credentials describes the temporary credentials written to the claim's Secret, if any.
+	// +optional
+	Credentials *CredentialsStatus `json:"credentials,omitempty"`
+
+	// Rotation records the rotation of the credentials written to the claim's Secret, if any.
+	// +optional
+	Rotation *CredentialRotationStatus `json:"rotation,omitempty"`
+}
+
+// CredentialRotationStatus records the rotation of the credentials written to the claim's Secret.
+type CredentialRotationStatus struct {
+	// LastRotationTime is when the credentials were last rotated.
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// LastRequest is the value of the rotate-credentials annotation last handled.
+	LastRequest string `json:"lastRequest,omitempty"`
+	// PendingRevocation is the previous access key, which is revoked once the overlap window ends.
+	PendingRevocation *PendingRevocation `json:"pendingRevocation,omitempty"`
+}
+
+// PendingRevocation identifies rotated credentials which are still valid.
+type PendingRevocation struct {
+	AccessKeyID string      `json:"accessKeyID"`
+	RevokeTime  metav1.Time `json:"revokeTime"`
+}
+
+// CredentialsStatus describes the credentials written to the claim's Secret.
+type CredentialsStatus struct {
+	// IssueTime is when the credentials were written to the Secret.
+	IssueTime *metav1.Time `json:"issueTime,omitempty"`
+	// ExpirationTime is when temporary credentials expire.
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
 }
 
 // +genclient
@@ -62,19 +62,53 @@ func TestAccessKeys_toMap(t *testing.T) {
 
 func TestAuthentication_ToMap(t *testing.T) {
 	type fields struct {
-		AccessKeys *AccessKeys
+		AccessKeys           *AccessKeys
+		AdditionalSecretData map[string]string
 	}
 	tests := []struct {
 		name   string
 		fields fields
 		want   map[string]string
 	}{
-		// TODO: Add test cases.
+		{
+			name: "access keys",
+			fields: fields{
+				AccessKeys: &AccessKeys{AccessKeyID: "id", SecretAccessKey: "secret"},
+			},
+			want: map[string]string{AwsKeyField: "id", AwsSecretField: "secret"},
+		},
+		{
+			name: "access keys with session token",
+			fields: fields{
+				AccessKeys: &AccessKeys{AccessKeyID: "id", SecretAccessKey: "secret", SessionToken: "token"},
+			},
+			want: map[string]string{AwsKeyField: "id", AwsSecretField: "secret", AwsSessionField: "token"},
+		},
+		{
+			name: "additional secret data only",
+			fields: fields{
+				AdditionalSecretData: map[string]string{"TOKEN": "token"},
+			},
+			want: map[string]string{"TOKEN": "token"},
+		},
+		{
+			name: "access keys take precedence over additional secret data",
+			fields: fields{
+				AccessKeys:           &AccessKeys{AccessKeyID: "id", SecretAccessKey: "secret"},
+				AdditionalSecretData: map[string]string{AwsKeyField: "other", "TOKEN": "token"},
+			},
+			want: map[string]string{AwsKeyField: "id", AwsSecretField: "secret", "TOKEN": "token"},
+		},
+		{
+			name: "empty",
+			want: map[string]string{},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := &Authentication{
-				AccessKeys: tt.fields.AccessKeys,
+				AccessKeys:           tt.fields.AccessKeys,
+				AdditionalSecretData: tt.fields.AdditionalSecretData,
 			}
 			if got := a.ToMap(); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Authentication.ToMap() = %v, want %v", got, tt.want)
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -55,6 +56,26 @@ type ObjectBucketClaimSpec struct {
 	// ObjectBucketName is the name of the object bucket resource. This is the authoritative
 	// determination for binding.
 	ObjectBucketName string `json:"objectBucketName,omitempty"`
+
+	// SecretName is the name of the Secret holding the bucket credentials. If empty, it is set
+	// from the generatedSecretName parameter of the storage class, or to the name of the claim.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// ConfigMapName is the name of the ConfigMap holding the bucket endpoint. If empty, it is set
+	// from the generatedConfigMapName parameter of the storage class, or to the name of the claim.
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// SecretKeyMapping renames the keys written to the Secret, e.g. AWS_ACCESS_KEY_ID to
+	// ACCESS_KEY. It overrides the secretKeyMapping parameter of the storage class.
+	// +optional
+	SecretKeyMapping map[string]string `json:"secretKeyMapping,omitempty"`
+
+	// ConfigMapKeyMapping renames the keys written to the ConfigMap, e.g. BUCKET_URL to
+	// AWS_ENDPOINT_URL. It overrides the configMapKeyMapping parameter of the storage class.
+	// +optional
+	ConfigMapKeyMapping map[string]string `json:"configMapKeyMapping,omitempty"`
 }
 
 // ObjectBucketClaimStatusPhase is set by the controller to save the state of the provisioning process.
@@ -70,14 +91,31 @@ const (
 	// ObjectBucketClaimStatusPhaseReleased TODO this would likely mean that the OB was deleted. That situation should never
 	// happen outside of the claim being deleted.  So this state shouldn't naturally arise out of automation.
 	ObjectBucketClaimStatusPhaseReleased = "Released"
-	// ObjectBucketClaimStatusPhaseFailed indicates that provisioning failed.  There should be no configMap, secret, or
-	// object bucket and no bucket should be left hanging in the object store
+	// ObjectBucketClaimStatusPhaseFailed indicates that provisioning failed permanently and will not be retried until
+	// the claim's spec changes.  Depending on how far provisioning got, the bucket, its object bucket, secret and
+	// configMap may exist, and are cleaned up when the claim is deleted.  The conditions hold the reason for the failure.
 	ObjectBucketClaimStatusPhaseFailed = "Failed"
 )
 
 // ObjectBucketClaimStatus defines the observed state of ObjectBucketClaim
 type ObjectBucketClaimStatus struct {
 	Phase ObjectBucketClaimStatusPhase `json:"phase,omitempty"`
+
+	// ObservedGeneration is the most recent generation of the claim observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions are the latest available observations of the claim's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Binding references the Secret holding the claim's Service Binding for Kubernetes
+	// (servicebinding.io) binding, which makes the claim a Provisioned Service. It is only set
+	// if the serviceBinding parameter of the claim's storage class is true.
+	// +optional
+	Binding *corev1.LocalObjectReference `json:"binding,omitempty"`
 }
 
 // +genclient
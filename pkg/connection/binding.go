@@ -0,0 +1,56 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connection
+
+import (
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+// BindingType is the type of the bindings returned by Binding.
+const BindingType = "s3"
+
+// Binding returns the entries of a Service Binding for Kubernetes (servicebinding.io) binding
+// Secret. The well-known type, provider, host, port, uri and certificates entries are set along
+// with the S3 specific endpoint, bucket, region, addressing-style, access-key-id,
+// secret-access-key and session-token entries. Empty entries are left out.
+func Binding(provider string, ep *v1alpha1.Endpoint, auth *v1alpha1.Authentication) (map[string]string, error) {
+	c, err := newDetails(ep, auth)
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]string{
+		"type":              BindingType,
+		"provider":          provider,
+		"host":              c.hostname,
+		"port":              c.port,
+		"uri":               c.URL,
+		"endpoint":          c.URL,
+		"certificates":      c.CABundle,
+		"bucket":            c.BucketName,
+		"region":            c.Region,
+		"addressing-style":  string(c.AddressingStyle),
+		"access-key-id":     c.AccessKeyID,
+		"secret-access-key": c.SecretAccessKey,
+		"session-token":     c.SessionToken,
+	}
+	for k, v := range entries {
+		if v == "" {
+			delete(entries, k)
+		}
+	}
+	return entries, nil
+}
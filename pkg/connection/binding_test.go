@@ -0,0 +1,59 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connection
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+func TestBinding(t *testing.T) {
+	ep := &v1alpha1.Endpoint{
+		BucketHost: "http://s3.example.com",
+		BucketPort: 8080,
+		BucketName: "bucket",
+		Region:     "us-east-1",
+		TLS:        &v1alpha1.EndpointTLS{CABundle: "PEM"},
+	}
+	auth := &v1alpha1.Authentication{
+		AccessKeys: &v1alpha1.AccessKeys{AccessKeyID: "key", SecretAccessKey: "secret"},
+	}
+	want := map[string]string{
+		"type":              "s3",
+		"provider":          "aws-s3.io/bucket",
+		"host":              "s3.example.com",
+		"port":              "8080",
+		"uri":               "http://s3.example.com:8080",
+		"endpoint":          "http://s3.example.com:8080",
+		"certificates":      "PEM",
+		"bucket":            "bucket",
+		"region":            "us-east-1",
+		"access-key-id":     "key",
+		"secret-access-key": "secret",
+	}
+
+	got, err := Binding("aws-s3.io/bucket", ep, auth)
+	if err != nil {
+		t.Fatalf("Binding() error = %v", err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf(cmp.Diff(want, got))
+	}
+}
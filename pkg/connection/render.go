@@ -0,0 +1,256 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connection
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	liberrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
+)
+
+// Format is a connection file format.
+type Format string
+
+// Supported formats. Each is rendered into the file named by FileName.
+const (
+	// FormatAWSCredentials is the default profile of an AWS shared credentials file.
+	FormatAWSCredentials Format = "aws-credentials"
+	// FormatAWSConfig is the default profile of an AWS shared config file.
+	FormatAWSConfig Format = "aws-config"
+	// FormatRclone is an rclone remote named "s3".
+	FormatRclone Format = "rclone"
+	// FormatS3cmd is an s3cmd configuration file.
+	FormatS3cmd Format = "s3cmd"
+	// FormatJSON is a JSON document containing all connection details.
+	FormatJSON Format = "json"
+)
+
+// RcloneRemote is the name of the remote rendered by FormatRclone.
+const RcloneRemote = "s3"
+
+var fileNames = map[Format]string{
+	FormatAWSCredentials: "credentials",
+	FormatAWSConfig:      "config",
+	FormatRclone:         "rclone.conf",
+	FormatS3cmd:          ".s3cfg",
+	FormatJSON:           "connection.json",
+}
+
+// FileName returns the name of the file the format is rendered into, which is also its key in
+// the claim's Secret.
+func FileName(f Format) string {
+	return fileNames[f]
+}
+
+// ParseFormats parses a comma separated list of formats, e.g. "aws-credentials,aws-config".
+func ParseFormats(s string) ([]Format, error) {
+	var formats []Format
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if _, ok := fileNames[Format(f)]; !ok {
+			return nil, liberrors.NewPermanentError(liberrors.ReasonInvalidParameters, fmt.Sprintf("unsupported connection format %q", f))
+		}
+		formats = append(formats, Format(f))
+	}
+	return formats, nil
+}
+
+// RenderAll renders the connection in each of the formats, keyed by their file names.
+func RenderAll(formats []Format, ep *v1alpha1.Endpoint, auth *v1alpha1.Authentication) (map[string]string, error) {
+	files := make(map[string]string, len(formats))
+	for _, f := range formats {
+		content, err := Render(f, ep, auth)
+		if err != nil {
+			return nil, err
+		}
+		files[FileName(f)] = content
+	}
+	return files, nil
+}
+
+// Render renders the connection in the given format. Either of ep and auth may be nil, in which
+// case the respective settings are left out.
+func Render(f Format, ep *v1alpha1.Endpoint, auth *v1alpha1.Authentication) (string, error) {
+	c, err := newDetails(ep, auth)
+	if err != nil {
+		return "", err
+	}
+	switch f {
+	case FormatAWSCredentials:
+		return c.awsCredentials(), nil
+	case FormatAWSConfig:
+		return c.awsConfig(), nil
+	case FormatRclone:
+		return c.rclone(), nil
+	case FormatS3cmd:
+		return c.s3cmd(), nil
+	case FormatJSON:
+		return c.json()
+	}
+	return "", fmt.Errorf("unsupported connection format %q", f)
+}
+
+// details holds the connection details in the shape of the JSON document. The other formats are
+// rendered from it as well.
+type details struct {
+	BucketName         string                   `json:"bucketName,omitempty"`
+	URL                string                   `json:"url,omitempty"`
+	Host               string                   `json:"host,omitempty"`
+	Port               int                      `json:"port,omitempty"`
+	Scheme             string                   `json:"scheme,omitempty"`
+	Region             string                   `json:"region,omitempty"`
+	SubRegion          string                   `json:"subRegion,omitempty"`
+	AddressingStyle    v1alpha1.AddressingStyle `json:"addressingStyle,omitempty"`
+	InsecureSkipVerify bool                     `json:"insecureSkipVerify,omitempty"`
+	CABundle           string                   `json:"caBundle,omitempty"`
+	AccessKeyID        string                   `json:"accessKeyID,omitempty"`
+	SecretAccessKey    string                   `json:"secretAccessKey,omitempty"`
+	SessionToken       string                   `json:"sessionToken,omitempty"`
+	Expiration         *time.Time               `json:"expiration,omitempty"`
+
+	// hostPort is the host and port of the URL, as expected by s3cmd, and hostname and port
+	// its parts
+	hostPort, hostname, port string
+}
+
+func newDetails(ep *v1alpha1.Endpoint, auth *v1alpha1.Authentication) (*details, error) {
+	c := &details{}
+	if ep != nil {
+		u, err := URL(ep)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil {
+			c.URL, c.Scheme, c.hostPort = u.String(), u.Scheme, u.Host
+			c.hostname, c.port = u.Hostname(), u.Port()
+		}
+		c.BucketName, c.Host, c.Port = ep.BucketName, ep.BucketHost, ep.BucketPort
+		c.Region, c.SubRegion, c.AddressingStyle = ep.Region, ep.SubRegion, ep.AddressingStyle
+		if ep.TLS != nil {
+			c.InsecureSkipVerify, c.CABundle = ep.TLS.InsecureSkipVerify, ep.TLS.CABundle
+		}
+	}
+	if auth != nil {
+		if auth.AccessKeys != nil {
+			c.AccessKeyID, c.SecretAccessKey, c.SessionToken = auth.AccessKeys.AccessKeyID, auth.AccessKeys.SecretAccessKey, auth.AccessKeys.SessionToken
+		}
+		if auth.ExpirationTime != nil {
+			t := auth.ExpirationTime.UTC()
+			c.Expiration = &t
+		}
+	}
+	return c, nil
+}
+
+// ini writes INI style "key = value" lines, leaving out empty values.
+type ini struct {
+	bytes.Buffer
+}
+
+func (w *ini) section(name string) {
+	fmt.Fprintf(w, "[%s]\n", name)
+}
+
+func (w *ini) set(key, value string) {
+	if value != "" {
+		fmt.Fprintf(w, "%s = %s\n", key, value)
+	}
+}
+
+func (c *details) awsCredentials() string {
+	var w ini
+	w.section("default")
+	w.set("aws_access_key_id", c.AccessKeyID)
+	w.set("aws_secret_access_key", c.SecretAccessKey)
+	w.set("aws_session_token", c.SessionToken)
+	return w.String()
+}
+
+// awsConfig leaves out the CA bundle, since the AWS config file only takes a path to one.
+func (c *details) awsConfig() string {
+	var w ini
+	w.section("default")
+	w.set("region", c.Region)
+	w.set("endpoint_url", c.URL)
+	if c.AddressingStyle != "" {
+		w.WriteString("s3 =\n")
+		fmt.Fprintf(&w, "    addressing_style = %s\n", c.AddressingStyle)
+	}
+	return w.String()
+}
+
+func (c *details) rclone() string {
+	var w ini
+	w.section(RcloneRemote)
+	w.set("type", "s3")
+	w.set("provider", "Other")
+	w.set("access_key_id", c.AccessKeyID)
+	w.set("secret_access_key", c.SecretAccessKey)
+	w.set("session_token", c.SessionToken)
+	w.set("endpoint", c.URL)
+	w.set("region", c.Region)
+	if c.AddressingStyle != "" {
+		w.set("force_path_style", strconv.FormatBool(c.AddressingStyle == v1alpha1.AddressingStylePath))
+	}
+	return w.String()
+}
+
+func (c *details) s3cmd() string {
+	var w ini
+	w.section("default")
+	w.set("access_key", c.AccessKeyID)
+	w.set("secret_key", c.SecretAccessKey)
+	w.set("access_token", c.SessionToken)
+	w.set("bucket_location", c.Region)
+	if c.hostPort != "" {
+		w.set("host_base", c.hostPort)
+		if c.AddressingStyle == v1alpha1.AddressingStyleVirtual {
+			w.set("host_bucket", "%(bucket)s."+c.hostPort)
+		} else {
+			w.set("host_bucket", c.hostPort)
+		}
+		w.set("use_https", pythonBool(c.Scheme == "https"))
+	}
+	if c.InsecureSkipVerify {
+		w.set("check_ssl_certificate", pythonBool(false))
+	}
+	return w.String()
+}
+
+func (c *details) json() (string, error) {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+func pythonBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
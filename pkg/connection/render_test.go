@@ -0,0 +1,158 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connection
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+func TestRender(t *testing.T) {
+	ep := &v1alpha1.Endpoint{
+		Scheme:          "https",
+		BucketHost:      "s3.example.com",
+		BucketPort:      8443,
+		BucketName:      "bucket",
+		Region:          "us-east-1",
+		AddressingStyle: v1alpha1.AddressingStyleVirtual,
+		TLS:             &v1alpha1.EndpointTLS{InsecureSkipVerify: true},
+	}
+	auth := &v1alpha1.Authentication{
+		AccessKeys: &v1alpha1.AccessKeys{AccessKeyID: "key", SecretAccessKey: "secret"},
+	}
+
+	tests := []struct {
+		name    string
+		format  Format
+		ep      *v1alpha1.Endpoint
+		auth    *v1alpha1.Authentication
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "aws credentials",
+			format: FormatAWSCredentials,
+			ep:     ep,
+			auth:   auth,
+			want:   "[default]\naws_access_key_id = key\naws_secret_access_key = secret\n",
+		},
+		{
+			name:   "aws credentials with session token",
+			format: FormatAWSCredentials,
+			auth: &v1alpha1.Authentication{
+				AccessKeys: &v1alpha1.AccessKeys{AccessKeyID: "key", SecretAccessKey: "secret", SessionToken: "token"},
+			},
+			want: "[default]\naws_access_key_id = key\naws_secret_access_key = secret\naws_session_token = token\n",
+		},
+		{
+			name:   "aws config",
+			format: FormatAWSConfig,
+			ep:     ep,
+			auth:   auth,
+			want:   "[default]\nregion = us-east-1\nendpoint_url = https://s3.example.com:8443\ns3 =\n    addressing_style = virtual\n",
+		},
+		{
+			name:   "aws config without endpoint",
+			format: FormatAWSConfig,
+			auth:   auth,
+			want:   "[default]\n",
+		},
+		{
+			name:   "rclone",
+			format: FormatRclone,
+			ep:     ep,
+			auth:   auth,
+			want: "[s3]\ntype = s3\nprovider = Other\naccess_key_id = key\nsecret_access_key = secret\n" +
+				"endpoint = https://s3.example.com:8443\nregion = us-east-1\nforce_path_style = false\n",
+		},
+		{
+			name:   "s3cmd",
+			format: FormatS3cmd,
+			ep:     ep,
+			auth:   auth,
+			want: "[default]\naccess_key = key\nsecret_key = secret\nbucket_location = us-east-1\n" +
+				"host_base = s3.example.com:8443\nhost_bucket = %(bucket)s.s3.example.com:8443\nuse_https = True\n" +
+				"check_ssl_certificate = False\n",
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			ep:     &v1alpha1.Endpoint{BucketHost: "s3.example.com", BucketPort: 80, BucketName: "bucket", Scheme: "http"},
+			auth:   auth,
+			want: `{
+  "bucketName": "bucket",
+  "url": "http://s3.example.com:80",
+  "host": "s3.example.com",
+  "port": 80,
+  "scheme": "http",
+  "accessKeyID": "key",
+  "secretAccessKey": "secret"
+}
+`,
+		},
+		{
+			name:    "invalid endpoint",
+			format:  FormatJSON,
+			ep:      &v1alpha1.Endpoint{BucketHost: "s3.example.com", Scheme: "ftp"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			format:  "yaml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.format, tt.ep, tt.auth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf(cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []Format
+		wantErr bool
+	}{
+		{name: "empty", in: "", want: nil},
+		{name: "single", in: "json", want: []Format{FormatJSON}},
+		{name: "list with spaces", in: "aws-credentials, aws-config,", want: []Format{FormatAWSCredentials, FormatAWSConfig}},
+		{name: "unsupported", in: "json,yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormats(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormats() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !cmp.Equal(tt.want, got) {
+				t.Errorf(cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
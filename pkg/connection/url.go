@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package connection renders the connection details of a bucket, i.e. its Endpoint and
+// Authentication, into the formats consumed by applications. It is used by the provisioner
+// controller to write the claim's Secret, and may be used by provisioners and other tools alike.
+package connection
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	liberrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
+)
+
+// URL returns the URL of the endpoint, or nil if the endpoint has no host or no scheme. Unless set explicitly, the
+// URL is composed from the scheme, host and port of the endpoint. The scheme is not guessed from the port, since
+// plain http endpoints are commonly served on ports such as 8080 or 9000. BucketHost may be a bare host name or IP address, with or
+// without brackets for IPv6, or a URL as written by earlier provisioners, e.g. http://s3.example.com.
+func URL(ep *v1alpha1.Endpoint) (*url.URL, error) {
+	invalid := func(format string, a ...interface{}) error {
+		return liberrors.NewPermanentError(liberrors.ReasonInvalidConnection, fmt.Sprintf(format, a...))
+	}
+	if ep.URL != "" {
+		u, err := url.Parse(ep.URL)
+		if err != nil {
+			return nil, invalid("invalid endpoint url %q: %v", ep.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, invalid("endpoint url %q must be an absolute http or https url", ep.URL)
+		}
+		return u, nil
+	}
+	if ep.BucketHost == "" {
+		return nil, nil
+	}
+
+	scheme, host, port := ep.Scheme, ep.BucketHost, ""
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return nil, invalid("invalid bucket host %q: %v", host, err)
+		}
+		if scheme == "" {
+			scheme = u.Scheme
+		}
+		host, port = u.Hostname(), u.Port()
+	} else {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if ep.BucketPort > 0 {
+		port = strconv.Itoa(ep.BucketPort)
+	}
+	if scheme == "" {
+		return nil, nil
+	}
+	if scheme != "http" && scheme != "https" {
+		return nil, invalid("unsupported endpoint scheme %q", scheme)
+	}
+
+	switch {
+	case port != "":
+		host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		host = "[" + host + "]"
+	}
+	return &url.URL{Scheme: scheme, Host: host}, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connection
+
+import (
+	"testing"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		ep      *v1alpha1.Endpoint
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "without host",
+			ep:   &v1alpha1.Endpoint{},
+			want: "",
+		},
+		{
+			name: "host name without scheme",
+			ep:   &v1alpha1.Endpoint{BucketHost: "s3.example.com", BucketPort: 80},
+			want: "",
+		},
+		{
+			name: "host name without port",
+			ep:   &v1alpha1.Endpoint{BucketHost: "s3.example.com", Scheme: "https"},
+			want: "https://s3.example.com",
+		},
+		{
+			name: "explicit scheme",
+			ep:   &v1alpha1.Endpoint{BucketHost: "s3.example.com", BucketPort: 8080, Scheme: "http"},
+			want: "http://s3.example.com:8080",
+		},
+		{
+			name: "host with scheme",
+			ep:   &v1alpha1.Endpoint{BucketHost: "http://s3.example.com", BucketPort: 8080},
+			want: "http://s3.example.com:8080",
+		},
+		{
+			name: "ipv6 address",
+			ep:   &v1alpha1.Endpoint{BucketHost: "fd00::1", BucketPort: 443, Scheme: "https"},
+			want: "https://[fd00::1]:443",
+		},
+		{
+			name: "bracketed ipv6 address without port",
+			ep:   &v1alpha1.Endpoint{BucketHost: "[fd00::1]", Scheme: "https"},
+			want: "https://[fd00::1]",
+		},
+		{
+			name: "ipv6 host with scheme",
+			ep:   &v1alpha1.Endpoint{BucketHost: "http://[fd00::1]:9000"},
+			want: "http://[fd00::1]:9000",
+		},
+		{
+			name: "explicit url",
+			ep:   &v1alpha1.Endpoint{BucketHost: "s3.example.com", URL: "https://s3.example.com/prefix"},
+			want: "https://s3.example.com/prefix",
+		},
+		{
+			name:    "relative url",
+			ep:      &v1alpha1.Endpoint{URL: "s3.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported scheme",
+			ep:      &v1alpha1.Endpoint{BucketHost: "s3.example.com", Scheme: "ftp"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := URL(tt.ep)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("URL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got string
+			if u != nil {
+				got = u.String()
+			}
+			if got != tt.want {
+				t.Errorf("URL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// BucketNameRules are the naming rules of an object store. The library normalizes and truncates
+// generateBucketName prefixes by the rules, and fails claims whose bucket names break them with a
+// permanent InvalidBucketName error, rather than passing them to the provisioner. S3BucketNameRules
+// are used unless a provisioner passes its own, e.g. for Azure containers, WithBucketNameRules.
+type BucketNameRules interface {
+	// MaxLength returns the maximum length of bucket names.
+	MaxLength() int
+	// NormalizePrefix adapts a claim's generateBucketName to the rules, e.g. by lower casing it.
+	// Generated names consist of the normalized prefix, a hyphen and a 36 character UUID.
+	NormalizePrefix(prefix string) string
+	// Validate returns the reasons why name breaks the rules, or nil if it is a valid name.
+	Validate(name string) []string
+}
+
+// S3BucketNameRules are the DNS compliant naming rules of S3 buckets.
+type S3BucketNameRules struct{}
+
+var _ BucketNameRules = S3BucketNameRules{}
+
+// s3BucketNameRegexp matches names of 3 to 63 lowercase letters, digits, dots and hyphens which
+// start and end with a letter or digit.
+var s3BucketNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// MaxLength implements BucketNameRules.
+func (S3BucketNameRules) MaxLength() int {
+	return 63
+}
+
+// NormalizePrefix implements BucketNameRules. It lower cases the prefix, replaces characters other
+// than letters, digits, dots and hyphens, such as underscores, by hyphens, collapses runs of dots and
+// hyphens into a single dot, or a hyphen if the run has one, and strips leading dots and hyphens and
+// trailing dots.
+func (S3BucketNameRules) NormalizePrefix(prefix string) string {
+	var b strings.Builder
+	// run holds the separator replacing the current run of dots and hyphens, if any
+	var run rune
+	for _, r := range prefix {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r >= 'A' && r <= 'Z':
+			r = r - 'A' + 'a'
+		case r == '.':
+			if run == 0 {
+				run = '.'
+			}
+			continue
+		default:
+			run = '-'
+			continue
+		}
+		if run != 0 {
+			b.WriteRune(run)
+			run = 0
+		}
+		b.WriteRune(r)
+	}
+	if run != 0 {
+		b.WriteRune(run)
+	}
+	return strings.TrimRight(strings.TrimLeft(b.String(), ".-"), ".")
+}
+
+// Validate implements BucketNameRules.
+func (S3BucketNameRules) Validate(name string) []string {
+	var errs []string
+	if !s3BucketNameRegexp.MatchString(name) {
+		errs = append(errs, "must be 3 to 63 lowercase letters, digits, dots or hyphens, starting and ending with a letter or digit")
+	}
+	if strings.Contains(name, "..") {
+		errs = append(errs, "must not contain two adjacent dots")
+	}
+	if net.ParseIP(name) != nil {
+		errs = append(errs, "must not be formatted as an IP address")
+	}
+	if strings.HasPrefix(name, "xn--") || strings.HasSuffix(name, "-s3alias") {
+		errs = append(errs, `must not start with "xn--" or end with "-s3alias"`)
+	}
+	return errs
+}
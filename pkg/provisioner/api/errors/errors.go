@@ -17,6 +17,7 @@ limitations under the License.
 package errors
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -38,8 +39,74 @@ func NewBucketExistsError(msg string) *BucketExistsErr {
 	}
 }
 
-// IsBucketExists returns true if the error is of type BucketExistsErr
-func IsBucketExists(e error) (is bool) {
-	_, is = e.(BucketExistsErr)
-	return is
-}
\ No newline at end of file
+// IsBucketExists returns true if the error, or any error it wraps, is a BucketExistsErr or a
+// pointer to one, as returned by NewBucketExistsError
+func IsBucketExists(e error) bool {
+	var existsErr BucketExistsErr
+	var existsPtr *BucketExistsErr
+	return errors.As(e, &existsErr) || errors.As(e, &existsPtr)
+}
+
+// Reasons which MAY be given to NewPermanentError. Provisioners are free to use their own reasons,
+// which should be CamelCase as they are surfaced as the reason of the claim's conditions.
+const (
+	ReasonInvalidParameters = "InvalidParameters"
+	ReasonQuotaDenied       = "QuotaDenied"
+	ReasonPolicyViolation   = "PolicyViolation"
+	// ReasonInvalidConnection is used by the library when the connection returned by the provisioner
+	// cannot be written to the claim's Secret or ConfigMap, e.g. due to reserved keys.
+	ReasonInvalidConnection = "InvalidConnection"
+	// ReasonNameConflict is used by the library when the claim's Secret or ConfigMap would replace
+	// an existing object which is not owned by the claim.
+	ReasonNameConflict = "NameConflict"
+	// ReasonInvalidBucketName is used by the library when the claim's bucket name breaks the
+	// bucket name rules of the provisioner.
+	ReasonInvalidBucketName = "InvalidBucketName"
+	// ReasonBucketExists is used by the library when the provisioner returns a BucketExistsErr for
+	// a claim's bucket name which cannot be, or could not be, regenerated.
+	ReasonBucketExists = "BucketExists"
+)
+
+// PermanentErr SHOULD be returned by Provisioner methods when the operation cannot succeed without
+// the claim or its storage class being changed, e.g. invalid parameters, a denied quota or a policy
+// violation. The controller marks the claim as Failed and stops retrying rather than requeuing it.
+// A Failed claim is retried once its spec is changed. Changing its storage class alone does not
+// retry it, the claim must then be edited, e.g. its additionalConfig, or recreated.
+type PermanentErr struct {
+	reason    string
+	errString string
+}
+
+// Error implements the Error interface
+func (e *PermanentErr) Error() string {
+	return e.errString
+}
+
+// Reason returns the CamelCase reason given when the error was constructed
+func (e *PermanentErr) Reason() string {
+	return e.reason
+}
+
+// NewPermanentError is a simple constructor for a PermanentErr
+func NewPermanentError(reason, msg string) *PermanentErr {
+	return &PermanentErr{
+		reason:    reason,
+		errString: msg,
+	}
+}
+
+// IsPermanent returns true if the error, or any error it wraps, is of type PermanentErr
+func IsPermanent(e error) bool {
+	var permErr *PermanentErr
+	return errors.As(e, &permErr)
+}
+
+// PermanentReason returns the reason of the PermanentErr wrapped by the error, or an empty string
+// if there is none
+func PermanentReason(e error) string {
+	var permErr *PermanentErr
+	if errors.As(e, &permErr) {
+		return permErr.Reason()
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+// The interfaces below may optionally be implemented by provisioners, in addition to Provisioner
+// or ProvisionerV2, to opt into additional behavior. The library detects them at runtime.
+
+// Updater reconfigures existing buckets. Provisioners implementing Updater have Update called,
+// instead of Provision or Grant, when the additionalConfig of a bound claim differs from the
+// config last applied to its ObjectBucket.
+type Updater interface {
+	// Update should apply options.ObjectBucketClaim.Spec.AdditionalConfig to the bucket of ob.
+	// Update must be idempotent.
+	// Update should return ob with its Connection updated, or nil if the Connection is unchanged.
+	// A returned Authentication causes the claim's Secret to be rewritten.
+	// Returning an errors.PermanentErr marks the claim Failed until its spec changes again.
+	Update(ctx context.Context, ob *v1alpha1.ObjectBucket, options *BucketOptions) (*v1alpha1.ObjectBucket, error)
+}
+
+// Refresher renews temporary credentials. Credentials are temporary if the Authentication returned
+// by Provision, Grant or Update has an ExpirationTime. Provisioners implementing Refresher have
+// Refresh called, instead of Provision or Grant, when the credentials of a bound claim are about
+// to expire.
+type Refresher interface {
+	// Refresh should return new credentials for the bucket of ob, typically with a later
+	// ExpirationTime. The claim's Secret is rewritten with the returned Authentication.
+	// Returning an errors.PermanentErr marks the claim Failed until its spec changes again.
+	Refresh(ctx context.Context, ob *v1alpha1.ObjectBucket, options *BucketOptions) (*v1alpha1.Authentication, error)
+}
+
+// CredentialRotator replaces the credentials of bound claims. Rotation is requested through the
+// rotate-credentials annotation of a claim, or periodically through the credentialRotationInterval
+// parameter of its storage class. The previous credentials stay valid for an overlap window, so
+// that workloads can pick up the new Secret, and are revoked afterwards.
+//
+// Once rotated, Provision and Grant must return the current rather than the original credentials.
+type CredentialRotator interface {
+	// RotateCredentials should create new credentials for the bucket of ob without invalidating
+	// the current ones. The claim's Secret is rewritten with the returned Authentication.
+	RotateCredentials(ctx context.Context, ob *v1alpha1.ObjectBucket, options *BucketOptions) (*v1alpha1.Authentication, error)
+	// RevokeCredentials should invalidate the previous credentials, identified by accessKeyID,
+	// once the overlap window has passed. RevokeCredentials must be idempotent.
+	RevokeCredentials(ctx context.Context, ob *v1alpha1.ObjectBucket, options *BucketOptions, accessKeyID string) error
+}
+
+// Eraser empties buckets. Provisioners implementing Eraser have Erase called, followed by Revoke,
+// when the claim of a new bucket with the Erase reclaim policy is deleted.
+type Eraser interface {
+	// Erase should delete the objects of the bucket of ob, keeping the bucket. Erase must be
+	// idempotent.
+	// Returning an errors.PermanentErr marks the ObjectBucket Failed and stops cleanup retries.
+	Erase(ctx context.Context, ob *v1alpha1.ObjectBucket) error
+}
+
+// Suspender disables access to buckets. Provisioners implementing Suspender have Suspend called,
+// instead of Revoke, when the claim of a new bucket with the Suspend reclaim policy is deleted.
+// The library then keeps the bucket's OB and the claim's Secret, which hold its credentials.
+type Suspender interface {
+	// Suspend should disable the credentials of the bucket of ob without deleting them, so that
+	// access may be restored later, e.g. by a claim granted access to the same bucket. Suspend
+	// must be idempotent.
+	// Returning an errors.PermanentErr marks the ObjectBucket Failed and stops cleanup retries.
+	Suspend(ctx context.Context, ob *v1alpha1.ObjectBucket) error
+}
+
+// Archiver retires buckets without deleting them. Provisioners implementing Archiver have Archive
+// called, followed by Revoke, when the claim of a new bucket with the Archive reclaim policy is
+// deleted.
+type Archiver interface {
+	// Archive should move the bucket of ob to cold storage, or tag it for later deletion.
+	// Archive must be idempotent.
+	// Returning an errors.PermanentErr marks the ObjectBucket Failed and stops cleanup retries.
+	Archive(ctx context.Context, ob *v1alpha1.ObjectBucket) error
+}
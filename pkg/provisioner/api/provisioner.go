@@ -17,6 +17,10 @@ limitations under the License.
 package api
 
 import (
+	"context"
+
+	"github.com/go-logr/logr"
+
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
@@ -45,16 +49,71 @@ type Provisioner interface {
 	// The Provision implementation does not need to clean up bucket or user resources when
 	// returning an error.
 	// The Provision implementation should return a nil ObjectBucket struct when returning an error.
+	// The Provision implementation should return an error of type errors.PermanentErr when retrying
+	// cannot succeed, e.g. due to invalid parameters, in order for the claim to be marked Failed.
 	Provision(options *BucketOptions) (*v1alpha1.ObjectBucket, error)
 	// Grant should be implemented to handle access to existing buckets.
 	// The Grant implementation must be idempotent.
 	Grant(options *BucketOptions) (*v1alpha1.ObjectBucket, error)
 	// Delete should be implemented to handle bucket deletion
+	// Returning an errors.PermanentErr marks the ObjectBucket Failed and stops cleanup retries.
 	Delete(ob *v1alpha1.ObjectBucket) error
 	// Revoke should be implemented to handle removing bucket access
+	// Returning an errors.PermanentErr marks the ObjectBucket Failed and stops cleanup retries.
 	Revoke(ob *v1alpha1.ObjectBucket) error
 }
 
+// ProvisionerV2 is the context aware version of Provisioner. Its methods have the same contract as
+// their Provisioner counterparts. The ctx passed to every method is cancelled when the provisioner
+// is shut down, carries the deadline configured with the WithCallTimeout option, if any, and
+// carries the request's logger, which may be retrieved with logr.FromContextOrDiscard.
+// Implementations should abort calls to the object store when ctx is done.
+type ProvisionerV2 interface {
+	GenerateUserID(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket) (string, error)
+	Provision(ctx context.Context, options *BucketOptions) (*v1alpha1.ObjectBucket, error)
+	Grant(ctx context.Context, options *BucketOptions) (*v1alpha1.ObjectBucket, error)
+	Delete(ctx context.Context, ob *v1alpha1.ObjectBucket) error
+	Revoke(ctx context.Context, ob *v1alpha1.ObjectBucket) error
+}
+
+// AdaptProvisioner returns a ProvisionerV2 which calls p, ignoring the ctx of each call.
+func AdaptProvisioner(p Provisioner) ProvisionerV2 {
+	return &provisionerAdapter{p: p}
+}
+
+// provisionerAdapter implements ProvisionerV2 for a Provisioner.
+type provisionerAdapter struct {
+	p Provisioner
+}
+
+var _ ProvisionerV2 = &provisionerAdapter{}
+
+func (a *provisionerAdapter) GenerateUserID(_ context.Context, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket) (string, error) {
+	return a.p.GenerateUserID(obc, ob)
+}
+
+func (a *provisionerAdapter) Provision(_ context.Context, options *BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	return a.p.Provision(options)
+}
+
+func (a *provisionerAdapter) Grant(_ context.Context, options *BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	return a.p.Grant(options)
+}
+
+func (a *provisionerAdapter) Delete(_ context.Context, ob *v1alpha1.ObjectBucket) error {
+	return a.p.Delete(ob)
+}
+
+func (a *provisionerAdapter) Revoke(_ context.Context, ob *v1alpha1.ObjectBucket) error {
+	return a.p.Revoke(ob)
+}
+
+// Unwrap returns the adapted Provisioner, allowing the optional interfaces it implements to be
+// discovered.
+func (a *provisionerAdapter) Unwrap() Provisioner {
+	return a.p
+}
+
 // BucketOptions wraps all pertinent data that the Provisioner requires to create a
 // bucket and the Reconciler requires to abstract that bucket in kubernetes
 type BucketOptions struct {
@@ -68,4 +127,7 @@ type BucketOptions struct {
 	ObjectBucketClaim *v1alpha1.ObjectBucketClaim
 	// Parameters is a complete copy of the OBC's storage class Parameters field
 	Parameters map[string]string
+	// Logger is the logger of the request which triggered the call, with the OBC's key injected.
+	// Provisioners may use it so that their log lines can be attributed to the OBC.
+	Logger logr.Logger
 }
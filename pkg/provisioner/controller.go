@@ -19,20 +19,26 @@ package provisioner
 import (
 	"context"
 	"fmt"
-	"os"
 	"reflect"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
+
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
@@ -40,6 +46,7 @@ import (
 	informers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/informers/externalversions/objectbucket.io/v1alpha1"
 	listers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/listers/objectbucket.io/v1alpha1"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+	liberrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
 )
 
 type controller interface {
@@ -52,61 +59,150 @@ type controller interface {
 type obcController struct {
 	clientset    kubernetes.Interface
 	libClientset versioned.Interface
-	obcLister    listers.ObjectBucketClaimLister
 	obLister     listers.ObjectBucketLister
-	obcInformer  informers.ObjectBucketClaimInformer
-	obcHasSynced cache.InformerSynced
-	obHasSynced  cache.InformerSynced
-	queue        workqueue.RateLimitingInterface
-	// static label containing provisioner name and provisioner-specific labels which are all added
-	// to the OB, OBC, configmap and secret
+	// obIndexer indexes OBs by claim, see claimIndex
+	obIndexer cache.Indexer
+	// obcInformers holds one informer per watched namespace, or a single cluster-wide informer.
+	// Informers of namespaces chosen by a label selector come and go while the controller runs.
+	obcInformersMu sync.RWMutex
+	obcInformers   []informers.ObjectBucketClaimInformer
+	// servesNamespace returns false for namespaces which are no longer watched. It is nil if the
+	// watched namespaces are fixed.
+	servesNamespace func(namespace string) bool
+	hasSynced       []cache.InformerSynced
+	queue           workqueue.RateLimitingInterface
+	recorder        record.EventRecorder
+	metrics         *metrics
+	workers         int
+	log             logr.Logger
+	// callTimeout bounds each call to the provisioner, if non-zero
+	callTimeout time.Duration
+	// refreshWindow is how long before their expiry temporary credentials are refreshed
+	refreshWindow time.Duration
+	// nameRules generate and validate the names of new buckets
+	nameRules api.BucketNameRules
+	// provisioner-specific labels which are added to the OB, OBC, configmap and secret along with
+	// the label of the claim's provisioner, see labelsFor
 	provisionerLabels map[string]string
-	provisioner       api.Provisioner
-	provisionerName   string
+	// provisioners serve the claims of the storage classes naming them
+	provisioners map[string]api.ProvisionerV2
+	// provisionerName identifies the controller in metrics, it joins the names of the provisioners
+	provisionerName string
+	// objectSelector selects the objects labeled with the name of any of the provisioners
+	objectSelector labels.Selector
 }
 
 var _ controller = &obcController{}
 
-func NewController(provisionerName string, provisioner api.Provisioner, clientset kubernetes.Interface, crdClientSet versioned.Interface, obcInformer informers.ObjectBucketClaimInformer, obInformer informers.ObjectBucketInformer) *obcController {
+// NewController returns a controller for the OBCs of the given informer. The number of workers
+// defaults to the value of the LIB_BUCKET_PROVISIONER_THREADS environment variable, if set. Options
+// such as WithEventRecorder and WithLogger apply as they do to NewProvisioner; events are written to
+// the API server unless a recorder is given.
+func NewController(provisionerName string, provisioner api.Provisioner, clientset kubernetes.Interface, crdClientSet versioned.Interface, obcInformer informers.ObjectBucketClaimInformer, obInformer informers.ObjectBucketInformer, opts ...Option) *obcController {
+	o := newOptions(opts...)
+	if o.recorder == nil {
+		o.recorder = newEventRecorder(newEventBroadcaster(clientset, o.logger), provisionerName)
+	}
+	provisioners := map[string]api.ProvisionerV2{provisionerName: api.AdaptProvisioner(provisioner)}
+	return newController(provisioners, clientset, crdClientSet, obcInformer, obInformer, o)
+}
+
+// newController returns a controller dispatching each claim to the provisioner named by its storage
+// class. The informers and the work queue are shared by all provisioners.
+func newController(provisioners map[string]api.ProvisionerV2, clientset kubernetes.Interface, crdClientSet versioned.Interface, obcInformer informers.ObjectBucketClaimInformer, obInformer informers.ObjectBucketInformer, o *options) *obcController {
+	names := provisionerNames(provisioners)
 	ctrl := &obcController{
-		clientset:    clientset,
-		libClientset: crdClientSet,
-		obcLister:    obcInformer.Lister(),
-		obLister:     obInformer.Lister(),
-		obcInformer:  obcInformer,
-		obcHasSynced: obcInformer.Informer().HasSynced,
-		obHasSynced:  obInformer.Informer().HasSynced,
-		queue:        workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		provisionerLabels: map[string]string{
-			provisionerLabelKey: labelValue(provisionerName),
-		},
-		provisionerName: provisionerName,
-		provisioner:     provisioner,
+		clientset:         clientset,
+		libClientset:      crdClientSet,
+		obLister:          obInformer.Lister(),
+		obIndexer:         obInformer.Informer().GetIndexer(),
+		hasSynced:         []cache.InformerSynced{obInformer.Informer().HasSynced},
+		queue:             workqueue.NewRateLimitingQueue(o.rateLimiter),
+		recorder:          o.recorder,
+		workers:           o.workers,
+		log:               o.logger,
+		callTimeout:       o.callTimeout,
+		refreshWindow:     o.refreshWindow,
+		nameRules:         o.nameRules,
+		provisionerLabels: map[string]string{},
+		provisioners:      provisioners,
+		provisionerName:   strings.Join(names, ","),
+		objectSelector:    provisionerSelector(names),
+	}
+	ctrl.metrics = newMetrics(ctrl)
+	// there is no claim informer yet if claims are watched per selected namespace
+	if obcInformer != nil {
+		ctrl.addClaimInformer(obcInformer)
 	}
 
-	obcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: ctrl.enqueueOBC,
+	if err := obInformer.Informer().AddIndexers(cache.Indexers{claimIndex: claimIndexFunc}); err != nil {
+		utilruntime.HandleError(fmt.Errorf("error indexing ObjectBuckets by claim: %v", err))
+	}
+
+	// Changes to, or deletion of, an OB re-queue its claim so that the OB is restored.
+	obInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: ctrl.isProvisionerObject,
+		Handler: cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(old, new interface{}) {
+				oldOb := old.(*v1alpha1.ObjectBucket)
+				newOb := new.(*v1alpha1.ObjectBucket)
+				// status updates, made by the controller itself, do not change the generation
+				if newOb.Generation == oldOb.Generation &&
+					reflect.DeepEqual(newOb.Labels, oldOb.Labels) &&
+					reflect.DeepEqual(newOb.Finalizers, oldOb.Finalizers) {
+					return
+				}
+				ctrl.enqueueClaimOfBucket(new)
+			},
+			DeleteFunc: ctrl.enqueueClaimOfBucket,
+		},
+	})
+	return ctrl
+}
+
+// addOwnedResourceInformers re-queues the owning OBC whenever a generated Secret or ConfigMap is
+// changed or deleted, so that it is restored. The informers are expected to be filtered by the
+// provisioner's label. It must be called before the controller is started.
+func (c *obcController) addOwnedResourceInformers(secretInformer coreinformers.SecretInformer, configMapInformer coreinformers.ConfigMapInformer) {
+	c.registerOwnedResourceInformers(secretInformer, configMapInformer)
+	c.hasSynced = append(c.hasSynced, secretInformer.Informer().HasSynced, configMapInformer.Informer().HasSynced)
+}
+
+// registerOwnedResourceInformers is like addOwnedResourceInformers but may be called while the
+// controller runs. The controller does not wait for the informers to sync.
+func (c *obcController) registerOwnedResourceInformers(secretInformer coreinformers.SecretInformer, configMapInformer coreinformers.ConfigMapInformer) {
+	handler := cache.ResourceEventHandlerFuncs{
 		UpdateFunc: func(old, new interface{}) {
-			oldObc := old.(*v1alpha1.ObjectBucketClaim)
-			newObc := new.(*v1alpha1.ObjectBucketClaim)
-			if newObc.ResourceVersion == oldObc.ResourceVersion {
+			if old.(metav1.Object).GetResourceVersion() == new.(metav1.Object).GetResourceVersion() {
 				// periodic re-sync can be ignored
 				return
 			}
-			// if old and new both have deletionTimestamps we can also ignore the
-			// update since these events are occurring on an obc marked for deletion,
-			// eg. extra finalizers being added and deleted.
-			if newObc.ObjectMeta.DeletionTimestamp != nil && oldObc.ObjectMeta.DeletionTimestamp != nil {
-				return
-			}
+			c.enqueueOwner(new)
+		},
+		DeleteFunc: c.enqueueOwner,
+	}
+	for _, informer := range []cache.SharedIndexInformer{secretInformer.Informer(), configMapInformer.Informer()} {
+		informer.AddEventHandler(handler)
+	}
+}
 
-			if !updateSupported(oldObc, newObc) {
-				return
-			}
+// addClaimInformer registers the controller's event handlers with the informer. It must be called
+// before the controller is started.
+func (c *obcController) addClaimInformer(obcInformer informers.ObjectBucketClaimInformer) {
+	c.registerClaimInformer(obcInformer)
+	c.hasSynced = append(c.hasSynced, obcInformer.Informer().HasSynced)
+}
 
-			// handle this update
-			ctrl.enqueueOBC(new)
-		},
+// registerClaimInformer is like addClaimInformer but may be called while the controller runs. The
+// controller does not wait for the informer to sync, its claims are queued as they are listed.
+func (c *obcController) registerClaimInformer(obcInformer informers.ObjectBucketClaimInformer) {
+	c.obcInformersMu.Lock()
+	c.obcInformers = append(c.obcInformers, obcInformer)
+	c.obcInformersMu.Unlock()
+
+	obcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueOBC,
+		UpdateFunc: c.updateOBC,
 		DeleteFunc: func(obj interface{}) {
 			// Since a finalizer is added to the obc and thus the obc will remain
 			// visible, we do not need to handle delete events here. Instead, obc
@@ -114,27 +210,125 @@ func NewController(provisionerName string, provisioner api.Provisioner, clientse
 			return
 		},
 	})
-	return ctrl
 }
 
+// removeClaimInformer forgets an informer given to registerClaimInformer. The informer must be
+// stopped by its owner.
+func (c *obcController) removeClaimInformer(obcInformer informers.ObjectBucketClaimInformer) {
+	c.obcInformersMu.Lock()
+	defer c.obcInformersMu.Unlock()
+	for i, informer := range c.obcInformers {
+		if informer == obcInformer {
+			c.obcInformers = append(c.obcInformers[:i], c.obcInformers[i+1:]...)
+			return
+		}
+	}
+}
+
+// claimInformers returns the claim informers of the currently watched namespaces.
+func (c *obcController) claimInformers() []informers.ObjectBucketClaimInformer {
+	c.obcInformersMu.RLock()
+	defer c.obcInformersMu.RUnlock()
+	return append([]informers.ObjectBucketClaimInformer(nil), c.obcInformers...)
+}
+
+// Start runs the workers until stopCh is closed. Closing stopCh also cancels the context of
+// in-flight requests, aborting calls to the provisioner and the API server.
 func (c *obcController) Start(stopCh <-chan struct{}) error {
 	defer utilruntime.HandleCrash()
 	defer c.queue.ShutDown()
 
-	if !cache.WaitForCacheSync(stopCh, c.obcHasSynced, c.obHasSynced) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if !cache.WaitForCacheSync(stopCh, c.hasSynced...) {
 		return fmt.Errorf("failed to wait for caches to sync ")
 	}
-	count := 1
-	if threadiness, set := os.LookupEnv("LIB_BUCKET_PROVISIONER_THREADS"); set {
-		count, _ = strconv.Atoi(threadiness)
-	}
-	for i := 0; i < count; i++ {
-		go wait.Until(c.runWorker, time.Second, stopCh)
+	c.migrateObjectBuckets(ctx)
+	for i := 0; i < c.workers; i++ {
+		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
 	}
 	<-stopCh
 	return nil
 }
 
+// regenerateBucketName replaces the generated bucket name of the claim and stores it in the claim's
+// spec before the bucket is provisioned, for the same reason the first generated name is.
+func (c *obcController) regenerateBucketName(ctx context.Context, obc *v1alpha1.ObjectBucketClaim) (*v1alpha1.ObjectBucketClaim, string, error) {
+	obc = obc.DeepCopy()
+	obc.Spec.BucketName = generateBucketName(obc.Spec.GenerateBucketName, c.nameRules)
+	obc, err := updateClaim(ctx, c.libClientset, obc)
+	if err != nil {
+		return obc, "", fmt.Errorf("error updating OBC with regenerated bucket name: %v", err)
+	}
+	return obc, obc.Spec.BucketName, nil
+}
+
+// migrateObjectBuckets links the OBs of this provisioner to their claims by UID. OBs of earlier
+// versions of the library were named after the namespace and name of their claim and referred to it
+// without a UID. Their names are kept, the UID of the claim is added to their claimRef and the name
+// is recorded in the claim's spec if it is missing, so that they are found by objectBucketForClaim
+// without regard to their name. The migration is best effort and safe to repeat: failures are
+// logged and retried on the next start.
+func (c *obcController) migrateObjectBuckets(ctx context.Context) {
+	obs, err := c.obLister.List(c.objectSelector)
+	if err != nil {
+		c.log.Error(err, "failed to list ObjectBuckets to migrate")
+		return
+	}
+	for _, ob := range obs {
+		if err := c.migrateObjectBucket(ctx, ob.DeepCopy()); err != nil {
+			c.log.Error(err, "failed to migrate ObjectBucket", "name", ob.Name)
+		}
+	}
+}
+
+func (c *obcController) migrateObjectBucket(ctx context.Context, ob *v1alpha1.ObjectBucket) error {
+	ref := ob.Spec.ClaimRef
+	if ref == nil || ref.Name == "" || ob.DeletionTimestamp != nil {
+		return nil
+	}
+	obc, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get claim %s/%s: %v", ref.Namespace, ref.Name, err)
+	}
+	if !claimRefMatches(ref, obc) {
+		c.log.Info("ObjectBucket is bound to a deleted claim of the same name, skipping", "name", ob.Name, "claim", ref.Namespace+"/"+ref.Name)
+		return nil
+	}
+	if obc.Spec.ObjectBucketName != "" && obc.Spec.ObjectBucketName != ob.Name {
+		c.log.Info("claim is bound to another ObjectBucket, skipping", "name", ob.Name, "claim", ref.Namespace+"/"+ref.Name,
+			"objectBucketName", obc.Spec.ObjectBucketName)
+		return nil
+	}
+
+	if ref.UID == "" {
+		ob.Spec.ClaimRef.UID = obc.UID
+		if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Update(ctx, ob, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to set claim UID: %v", err)
+		}
+		c.log.Info("set claim UID of ObjectBucket", "name", ob.Name, "uid", obc.UID)
+	}
+	if obc.Spec.ObjectBucketName == "" {
+		obc.Spec.ObjectBucketName = ob.Name
+		if _, err = updateClaim(ctx, c.libClientset, obc); err != nil {
+			return err
+		}
+		c.log.Info("recorded ObjectBucket name in claim", "name", ob.Name, "claim", ref.Namespace+"/"+ref.Name)
+	}
+	return nil
+}
+
 // add provisioner-specific labels to the existing static label in the obcController struct.
 func (c *obcController) SetLabels(labels map[string]string) {
 	for k, v := range labels {
@@ -142,6 +336,45 @@ func (c *obcController) SetLabels(labels map[string]string) {
 	}
 }
 
+// labelsFor returns the labels of the objects generated for the claims of the named provisioner.
+func (c *obcController) labelsFor(provisionerName string) map[string]string {
+	objLabels := make(map[string]string, len(c.provisionerLabels)+1)
+	for k, v := range c.provisionerLabels {
+		objLabels[k] = v
+	}
+	objLabels[provisionerLabelKey] = labelValue(provisionerName)
+	return objLabels
+}
+
+// provisionerFor returns the provisioner of the storage class, which must be supported.
+func (c *obcController) provisionerFor(class *storagev1.StorageClass) api.ProvisionerV2 {
+	return c.provisioners[class.Provisioner]
+}
+
+// updateOBC queues an updated OBC, unless the update is a periodic re-sync or a change the
+// controller does not act on.
+func (c *obcController) updateOBC(old, new interface{}) {
+	oldObc := old.(*v1alpha1.ObjectBucketClaim)
+	newObc := new.(*v1alpha1.ObjectBucketClaim)
+	if newObc.ResourceVersion == oldObc.ResourceVersion {
+		// periodic re-sync can be ignored
+		return
+	}
+	// if old and new both have deletionTimestamps we can also ignore the
+	// update since these events are occurring on an obc marked for deletion,
+	// eg. extra finalizers being added and deleted.
+	if newObc.ObjectMeta.DeletionTimestamp != nil && oldObc.ObjectMeta.DeletionTimestamp != nil {
+		return
+	}
+
+	if !updateSupported(c.log, oldObc, newObc) {
+		return
+	}
+
+	// handle this update
+	c.enqueueOBC(new)
+}
+
 func (c *obcController) enqueueOBC(obj interface{}) {
 	var key string
 	var err error
@@ -152,12 +385,57 @@ func (c *obcController) enqueueOBC(obj interface{}) {
 	c.queue.AddRateLimited(key)
 }
 
-func (c *obcController) runWorker() {
-	for c.processNextItemInQueue() {
+// isProvisionerObject returns true if obj carries the label of one of this controller's provisioners.
+func (c *obcController) isProvisionerObject(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
 	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return c.objectSelector.Matches(labels.Set(o.GetLabels()))
 }
 
-func (c *obcController) processNextItemInQueue() bool {
+// enqueueOwner adds the key of the OBC owning obj to the queue.
+func (c *obcController) enqueueOwner(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, ref := range o.GetOwnerReferences() {
+		if ref.Kind == v1alpha1.ObjectBucketClaimGVK().Kind {
+			c.queue.Add(o.GetNamespace() + "/" + ref.Name)
+		}
+	}
+}
+
+// enqueueClaimOfBucket adds the key of the OBC bound to obj, an OB, to the queue.
+func (c *obcController) enqueueClaimOfBucket(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	ob, ok := obj.(*v1alpha1.ObjectBucket)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("expected ObjectBucket but got %#v", obj))
+		return
+	}
+	if ob.Spec.ClaimRef == nil || ob.Spec.ClaimRef.Name == "" {
+		return
+	}
+	c.queue.Add(ob.Spec.ClaimRef.Namespace + "/" + ob.Spec.ClaimRef.Name)
+}
+
+func (c *obcController) runWorker(ctx context.Context) {
+	for c.processNextItemInQueue(ctx) {
+	}
+}
+
+func (c *obcController) processNextItemInQueue(ctx context.Context) bool {
 	obj, shutdown := c.queue.Get()
 	if shutdown {
 		return false
@@ -189,9 +467,10 @@ func (c *obcController) processNextItemInQueue() bool {
 		}
 		// Run the syncHandler, passing it the namespace/name string of the
 		// Foo resource to be synced.
-		if err := c.syncHandler(key); err != nil {
+		if err := c.syncHandler(ctx, key); err != nil {
 			// Put the item back on the workqueue to handle any transient errors.
 			c.queue.AddRateLimited(key)
+			c.metrics.queueRetries.Inc()
 			return fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
 		}
 		// Finally, if no error occurs we Forget this item so it does not
@@ -211,12 +490,21 @@ func (c *obcController) processNextItemInQueue() bool {
 // Note: the obc obtained from the key is not expected to be nil. In other words, this func is
 // not called when informers detect an object is missing and trigger a formal delete event.
 // Instead, delete is indicated by the deletionTimestamp being non-nil on an update event.
-func (c *obcController) syncHandler(key string) error {
+func (c *obcController) syncHandler(ctx context.Context, key string) (err error) {
 
-	setLoggersWithRequest(key)
-	logD.Info("reconciling claim")
+	ctx = withRequestLogger(ctx, c.log, key)
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("reconciling claim")
 
-	obc, err := claimForKey(key, c.libClientset)
+	outcome := outcomeSuccess
+	defer func() {
+		if err != nil {
+			outcome = outcomeError
+		}
+		c.metrics.syncTotal.WithLabelValues(outcome).Inc()
+	}()
+
+	obc, err := claimForKey(ctx, key, c.libClientset)
 	if err != nil {
 		//      The OBC was deleted immediately after creation, before it could be processed by
 		//      handleProvisionClaim.  As a finalizer is immediately applied to the OBC before processing,
@@ -224,17 +512,29 @@ func (c *obcController) syncHandler(key string) error {
 		//      Therefore, it is safe to assume nothing needs to be done.
 		if errors.IsNotFound(err) {
 			log.Info("OBC vanished, assuming it was deleted")
+			outcome = outcomeSkipped
 			return nil
 		}
 		return fmt.Errorf("could not sync OBC %s: %v", key, err)
 	}
 
-	class, err := storageClassForClaim(c.clientset, obc)
+	// Claims of namespaces which are no longer watched are left as they are, unless they are bound
+	// or being deleted, so that their buckets are still cleaned up.
+	if c.servesNamespace != nil && !c.servesNamespace(obc.Namespace) &&
+		obc.DeletionTimestamp == nil && obc.Spec.ObjectBucketName == "" && obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		log.Info("namespace is no longer watched, skipping claim")
+		outcome = outcomeSkipped
+		return nil
+	}
+
+	class, err := storageClassForClaim(ctx, c.clientset, obc)
 	if err != nil {
+		c.recorder.Event(obc, corev1.EventTypeWarning, reasonStorageClassLookupFailed, err.Error())
 		return err
 	}
 	if !c.supportedProvisioner(class.Provisioner) {
 		log.Info("unsupported provisioner", "got", class.Provisioner)
+		outcome = outcomeSkipped
 		return nil
 	}
 
@@ -243,46 +543,112 @@ func (c *obcController) syncHandler(key string) error {
 	// ***********************
 	if obc.ObjectMeta.DeletionTimestamp != nil {
 		log.Info("OBC deleted, proceeding with cleanup")
-		return c.handleDeleteClaim(key, obc)
+		return c.handleDeleteClaim(ctx, key, obc, class)
+	}
+
+	// A claim which failed permanently is not retried until its spec is changed. Changes to its
+	// storage class do not re-queue it.
+	if obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseFailed && obc.Status.ObservedGeneration == obc.Generation {
+		log.V(1).Info("claim failed permanently, skipping until its spec changes")
+		outcome = outcomeSkipped
+		return nil
 	}
 
-	if obc.Status.Phase == "" {
+	if obc.Status.Phase == "" || obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseFailed {
 		// update the OBC's status to pending before any provisioning related errors can occur
-		obc, err = updateObjectBucketClaimPhase(
-			c.libClientset,
+		obc, err = updateObjectBucketClaimPhase(ctx, c.libClientset,
 			obc,
-			v1alpha1.ObjectBucketClaimStatusPhasePending)
+			v1alpha1.ObjectBucketClaimStatusPhasePending,
+			newCondition(v1alpha1.ConditionProvisioned, metav1.ConditionFalse, v1alpha1.ReasonProvisioning, "waiting for the bucket to be provisioned"))
 		if err != nil {
 			return fmt.Errorf("error updating OBC status: %s", err)
 		}
+		c.recorder.Event(obc, corev1.EventTypeNormal, reasonPending, "claim is pending provisioning")
 	}
 
-	// idempotent provisioner
-	err = c.handleProvisionClaim(key, obc, class)
+	// A bound bucket is reconfigured if its claim's additionalConfig changed and the provisioner
+	// implements Updater, its expiring credentials are refreshed if the provisioner implements
+	// Refresher and its credentials are rotated if the provisioner implements CredentialRotator.
+	// Otherwise the claim goes through the idempotent provisioning path.
+	failedCondition := v1alpha1.ConditionProvisioned
+	handled := false
+	for _, h := range []struct {
+		handle    func(context.Context, string, *v1alpha1.ObjectBucketClaim, *storagev1.StorageClass) (bool, error)
+		condition string
+	}{
+		{c.handleUpdateClaim, v1alpha1.ConditionConfigApplied},
+		{c.handleRefreshClaim, v1alpha1.ConditionCredentialsReady},
+		{c.handleRotateClaim, v1alpha1.ConditionCredentialsReady},
+	} {
+		if handled, err = h.handle(ctx, key, obc, class); handled {
+			failedCondition = h.condition
+			break
+		}
+	}
+	if !handled {
+		// idempotent provisioner
+		err = c.handleProvisionClaim(ctx, key, obc, class)
+		if err != nil {
+			c.recorder.Event(obc, corev1.EventTypeWarning, reasonProvisioningFailed, err.Error())
+		}
+	}
 
-	// If the handler above errors, the request will be re-queued. In the distant future, we will
-	// likely want some ignorable error types in order to skip re-queuing
+	// Permanent errors fail the claim rather than re-queuing it. Any other error results in the
+	// request being re-queued.
+	if liberrors.IsPermanent(err) {
+		log.Error(err, "provisioning failed permanently")
+		cond := newCondition(failedCondition, metav1.ConditionFalse, liberrors.PermanentReason(err), err.Error())
+		if err = failObjectBucketClaim(ctx, c.libClientset, obc, cond); err != nil {
+			return fmt.Errorf("error updating OBC %q's status to %q: %v", key, v1alpha1.ObjectBucketClaimStatusPhaseFailed, err)
+		}
+		c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonFailed, "claim failed permanently: %s", cond.Message)
+		outcome = outcomeFailed
+		return nil
+	}
 	return err
 }
 
-func (c *obcController) handleProvisionClaim(key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) error {
+func (c *obcController) handleProvisionClaim(ctx context.Context, key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
 
 	log.Info("syncing obc creation")
 
 	var (
-		ob  *v1alpha1.ObjectBucket
-		err error
+		ob         *v1alpha1.ObjectBucket
+		conditions []metav1.Condition
 	)
 
+	// On failure, record the conditions gathered so far so that the OBC's status reflects how far
+	// provisioning got. Failures which occur before the provisioner is called are attributed to
+	// the Provisioned condition.
+	defer func() {
+		if err == nil {
+			return
+		}
+		if len(conditions) == 0 {
+			conditions = append(conditions, newCondition(v1alpha1.ConditionProvisioned, metav1.ConditionFalse, v1alpha1.ReasonProvisioningFailed, err.Error()))
+		}
+		if condErr := updateObjectBucketClaimConditions(ctx, c.libClientset, obc, conditions...); condErr != nil {
+			log.Error(condErr, "error recording OBC conditions")
+		}
+	}()
+
 	// set finalizer in OBC so that resources cleaned up is controlled when the obc is deleted
-	if obc, err = c.setOBCMetaFields(obc); err != nil {
+	if obc, err = c.setOBCMetaFields(ctx, obc, class); err != nil {
 		return err
 	}
 
-	ob, err = getObFromKey(key, c.libClientset) // ob may be nil here
+	ob, err = c.objectBucketForClaim(ctx, obc) // ob may be nil here
 	if err != nil {
 		return fmt.Errorf("failed to find ob associated with obc %q", obc.Name)
 	}
+	// the name and rotation state of a bound bucket are kept, the provisioner does not return them
+	obName := objectBucketNameForClaim(obc)
+	var rotation *v1alpha1.CredentialRotationStatus
+	if ob != nil {
+		obName = ob.Name
+		rotation = ob.Status.Rotation
+	}
 
 	// on an operator restart, the event will be an add event, and we should check if the obc has
 	// been updated in comparison to the ob, since we don't have an old OBC to compare to
@@ -298,52 +664,114 @@ func (c *obcController) handleProvisionClaim(key string, obc *v1alpha1.ObjectBuc
 
 	bucketName := class.Parameters[v1alpha1.StorageClassBucket]
 	if isDynamicProvisioning {
-		bucketName, err = composeBucketName(obc)
+		bucketName, err = composeBucketName(obc, c.nameRules)
 		if err != nil {
 			return fmt.Errorf("error composing bucket name: %v", err)
 		}
+		// Buckets which were provisioned already keep their names, even if they break the rules.
+		if ob == nil {
+			if err = validateBucketName(bucketName, c.nameRules); err != nil {
+				return err
+			}
+		}
 	}
 	if len(bucketName) == 0 {
 		return fmt.Errorf("bucket name missing")
 	}
 
+	// Only bucket names generated by the library are replaced when they are taken. A name is
+	// generated if the spec has none yet, or has one which was generated and stored on an earlier
+	// attempt. An explicit bucketName is kept even if generateBucketName is set as well.
+	generatedName := obc.Spec.BucketName == "" || isGeneratedBucketName(obc.Spec.BucketName, obc.Spec.GenerateBucketName, c.nameRules)
+
 	// In the case where a bucket name is being generated, generate the name and store it in the OBC
 	// spec before doing any Provisioning so that any crashes encountered in this code will not
 	// result in multiple buckets being generated for the same OBC. bucketName takes precedence over
-	// generateBucketName if both are present.
-	if obc.Spec.BucketName == "" {
-		obc.Spec.BucketName = bucketName
-		obc, err = updateClaim(
-			c.libClientset,
+	// generateBucketName if both are present. The names of the Secret and ConfigMap are stored
+	// alongside it.
+	if obc.Spec.BucketName == "" || obc.Spec.SecretName == "" || obc.Spec.ConfigMapName == "" {
+		if obc.Spec.BucketName == "" {
+			obc.Spec.BucketName = bucketName
+		}
+		if err = setGeneratedObjectNames(obc, class, ob != nil || obc.Spec.ObjectBucketName != ""); err != nil {
+			return err
+		}
+		obc, err = updateClaim(ctx, c.libClientset,
 			obc)
 		if err != nil {
 			return fmt.Errorf("error updating OBC %q with bucket name: %v", key, err)
 		}
 	}
 
-	userID, err := c.provisioner.GenerateUserID(obc, ob)
+	// The Secret and ConfigMap are checked before the bucket is provisioned, since a claim which
+	// cannot be bound would otherwise leave the bucket behind until the claim is deleted.
+	if err = checkGeneratedObjects(ctx, obc, class, c.clientset); err != nil {
+		return err
+	}
+
+	reclaimPolicy, err := c.reclaimPolicyFor(class)
+	if err != nil {
+		return err
+	}
+
+	callCtx, cancel := c.callContext(ctx)
+	userID, err := c.provisionerFor(class).GenerateUserID(callCtx, obc, ob)
+	cancel()
 	if err != nil {
 		return fmt.Errorf("failed to generate user id for use as idempotency key: %v", err)
 	}
 
 	options := &api.BucketOptions{
-		ReclaimPolicy:     class.ReclaimPolicy,
+		ReclaimPolicy:     reclaimPolicy,
 		BucketName:        bucketName,
 		UserID:            userID,
 		ObjectBucketClaim: obc.DeepCopy(),
 		Parameters:        class.Parameters,
+		Logger:            log,
 	}
 
 	verb := "provisioning"
 	if !isDynamicProvisioning {
 		verb = "granting access to"
 	}
-	logD.Info(verb, "bucket", options.BucketName)
+	log.V(1).Info(verb, "bucket", options.BucketName)
+	c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonProvisioning, "%s bucket %q", verb, options.BucketName)
+
+	// A generated bucket name which is taken in the object store is replaced by a fresh one, up to
+	// maxBucketNameRegenerations times. An explicit bucket name which is taken fails the claim.
+	for regenerations := 0; ; regenerations++ {
+		callCtx, cancel = c.callContext(ctx)
+		start := time.Now()
+		if isDynamicProvisioning {
+			ob, err = c.provisionerFor(class).Provision(callCtx, options)
+			c.metrics.observeCall(operationProvision, start, err)
+		} else {
+			ob, err = c.provisionerFor(class).Grant(callCtx, options)
+			c.metrics.observeCall(operationGrant, start, err)
+		}
+		cancel()
 
-	if isDynamicProvisioning {
-		ob, err = c.provisioner.Provision(options)
-	} else {
-		ob, err = c.provisioner.Grant(options)
+		if !isDynamicProvisioning || !liberrors.IsBucketExists(err) {
+			break
+		}
+		if !generatedName {
+			err = liberrors.NewPermanentError(liberrors.ReasonBucketExists,
+				fmt.Sprintf("bucket %q already exists: %v", bucketName, err))
+			break
+		}
+		if regenerations == maxBucketNameRegenerations {
+			err = liberrors.NewPermanentError(liberrors.ReasonBucketExists,
+				fmt.Sprintf("generated bucket names were taken %d times, last %q: %v", regenerations+1, bucketName, err))
+			break
+		}
+		previous := bucketName
+		if obc, bucketName, err = c.regenerateBucketName(ctx, obc); err != nil {
+			return err
+		}
+		log.Info("bucket name is taken, provisioning with a regenerated name", "previous", previous, "bucket", bucketName)
+		c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonBucketNameRegenerated, "bucket %q is taken, provisioning bucket %q", previous, bucketName)
+		options.BucketName = bucketName
+		options.ObjectBucketClaim = obc.DeepCopy()
 	}
 
 	// The k8s code generator does not generate equality methods, and golang's native
@@ -351,86 +779,463 @@ func (c *obcController) handleProvisionClaim(key string, obc *v1alpha1.ObjectBuc
 	emptyBucket := (ob == nil || apiequality.Semantic.DeepEqual(*ob, v1alpha1.ObjectBucket{}))
 
 	if err != nil {
-		return fmt.Errorf("error %s bucket: %v", verb, err)
+		err = fmt.Errorf("error %s bucket: %w", verb, err)
 	} else if emptyBucket {
-		return fmt.Errorf("provisioner returned empty object bucket")
+		err = fmt.Errorf("provisioner returned empty object bucket")
 	}
-
-	// Create/Update auth secret and endpoint configmap
-	err = createOrUpdateSecret(
-		obc,
-		ob.Spec.Authentication,
-		c.provisionerLabels,
-		c.clientset)
 	if err != nil {
-		return fmt.Errorf("error creating secret for OBC: %v", err)
-	}
-	err = createOrUpdateConfigMap(
-		obc,
-		ob.Spec.Endpoint,
-		c.provisionerLabels,
-		c.clientset)
-	if err != nil {
-		return fmt.Errorf("error creating configmap for OBC: %v", err)
+		conditions = append(conditions, newCondition(v1alpha1.ConditionProvisioned, metav1.ConditionFalse, v1alpha1.ReasonProvisioningFailed, err.Error()))
+		return err
 	}
+	conditions = append(conditions, newCondition(v1alpha1.ConditionProvisioned, metav1.ConditionTrue, v1alpha1.ReasonProvisioned, fmt.Sprintf("bucket %q is available", bucketName)))
+
+	// The OB's Authentication is not persisted, so it is kept for the Secret and the expiry of
+	// temporary credentials is recorded in the OB's status.
+	auth := ob.Spec.Authentication
+	credentials := credentialsStatus(auth)
 
-	// Create/Update OB
-	setObjectBucketName(ob, key)
+	// Create/Update OB. It is written before the Secret and ConfigMap so that the bucket is cleaned
+	// up when the claim is deleted, even if writing them fails permanently.
+	ob.Name = obName
 	ob.Spec.StorageClassName = obc.Spec.StorageClassName
 	if ob.Spec.ReclaimPolicy == nil || *ob.Spec.ReclaimPolicy == corev1.PersistentVolumeReclaimPolicy("") {
 		// Do not blindly overwrite the reclaim policy. The provisioner might have reason to
 		// specify a reclaim policy that is  different from the storage class.
 		ob.Spec.ReclaimPolicy = options.ReclaimPolicy
 	}
-	addLabels(ob, c.provisionerLabels)
+	addLabels(ctx, ob, c.labelsFor(class.Provisioner))
 	addFinalizers(ob, []string{finalizer})
-	ob.Spec.ClaimRef, err = claimRefForKey(key, c.libClientset)
+	ob.Spec.ClaimRef, err = claimRefForKey(ctx, key, c.libClientset)
 	if err != nil {
 		return fmt.Errorf("error getting reference to OBC: %v", err)
 	}
-	ob, err = createOrUpdateObjectBucket(
-		ob,
+	ob, err = createOrUpdateObjectBucket(ctx, ob,
 		c.libClientset)
 	if err != nil {
 		return fmt.Errorf("error creating or updating OB %q: %v", ob.Name, err)
 	}
 
+	// Create/Update auth secret and endpoint configmap
+	err = createOrUpdateSecret(ctx, obc, class,
+		auth,
+		ob.Spec.Endpoint,
+		c.labelsFor(class.Provisioner),
+		c.clientset)
+	if err != nil {
+		err = fmt.Errorf("error creating secret for OBC: %w", err)
+		conditions = append(conditions, newCondition(v1alpha1.ConditionCredentialsReady, metav1.ConditionFalse, v1alpha1.ReasonSecretFailed, err.Error()))
+		return err
+	}
+	conditions = append(conditions, newCondition(v1alpha1.ConditionCredentialsReady, metav1.ConditionTrue, v1alpha1.ReasonSecretCreated, fmt.Sprintf("credentials written to Secret %q", composeSecretName(obc))))
+	err = createOrUpdateConfigMap(ctx, obc, class,
+		ob.Spec.Endpoint,
+		c.labelsFor(class.Provisioner),
+		c.clientset)
+	if err != nil {
+		err = fmt.Errorf("error creating configmap for OBC: %w", err)
+		conditions = append(conditions, newCondition(v1alpha1.ConditionEndpointReady, metav1.ConditionFalse, v1alpha1.ReasonConfigMapFailed, err.Error()))
+		return err
+	}
+	conditions = append(conditions, newCondition(v1alpha1.ConditionEndpointReady, metav1.ConditionTrue, v1alpha1.ReasonConfigMapCreated, fmt.Sprintf("endpoint written to ConfigMap %q", composeConfigMapName(obc))))
+
 	// Status must be set/updated separately from OB spec
 	ob.Status.Phase = v1alpha1.ObjectBucketStatusPhaseBound
-	ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(context.TODO(), ob, metav1.UpdateOptions{})
+	ob.Status.AppliedAdditionalConfig = obc.Spec.AdditionalConfig
+	ob.Status.Credentials = credentials
+	ob.Status.Rotation = rotation
+	setBucketConditions(ob, conditions...)
+	ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(ctx, ob, metav1.UpdateOptions{})
 	if err != nil {
 		return fmt.Errorf("error updating OB %q status to %q", ob.Name, ob.Status.Phase)
 	}
+	c.recorder.Eventf(ob, corev1.EventTypeNormal, reasonBound, "bound to claim %q", key)
+	c.requeueForRefresh(ctx, key, ob.Status.Credentials)
 
 	// update OBC
 	obc.Spec.ObjectBucketName = ob.Name
 	obc.Spec.BucketName = bucketName
-	obc, err = updateClaim(
-		c.libClientset,
+	obc, err = updateClaim(ctx, c.libClientset,
 		obc)
 	if err != nil {
 		return fmt.Errorf("error updating OBC: %v", err)
 	}
-	obc, err = updateObjectBucketClaimPhase(
-		c.libClientset,
+	obc.Status.Binding = bindingReference(obc, class)
+	obc, err = updateObjectBucketClaimPhase(ctx, c.libClientset,
 		obc,
-		v1alpha1.ObjectBucketClaimStatusPhaseBound)
+		v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		conditions...)
 	if err != nil {
 		return fmt.Errorf("error updating OBC %q's status to %q: %v", obc.Name, v1alpha1.ObjectBucketClaimStatusPhaseBound, err)
 	}
+	c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonBound, "bound to ObjectBucket %q", ob.Name)
 
 	return nil
 }
 
+// handleUpdateClaim calls the provisioner's Update method if the claim's bucket is bound and the
+// claim's additionalConfig differs from the config last applied to the bucket. updating is false,
+// and nothing is done, if the provisioner does not implement api.Updater or no update is needed.
+func (c *obcController) handleUpdateClaim(ctx context.Context, key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (updating bool, err error) {
+	log := logr.FromContextOrDiscard(ctx)
+
+	updater, ok := unwrapProvisioner(c.provisionerFor(class)).(api.Updater)
+	if !ok {
+		return false, nil
+	}
+	ob, err := c.objectBucketForClaim(ctx, obc)
+	if err != nil {
+		return true, fmt.Errorf("failed to find ob associated with obc %q: %v", obc.Name, err)
+	}
+	if ob == nil || ob.Status.Phase != v1alpha1.ObjectBucketStatusPhaseBound || !additionalConfigChanged(ob, obc) {
+		return false, nil
+	}
+
+	log.Info("syncing obc additionalConfig update")
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		c.recorder.Event(obc, corev1.EventTypeWarning, reasonUpdateFailed, err.Error())
+		cond := newCondition(v1alpha1.ConditionConfigApplied, metav1.ConditionFalse, v1alpha1.ReasonUpdateFailed, err.Error())
+		if condErr := updateObjectBucketClaimConditions(ctx, c.libClientset, obc, cond); condErr != nil {
+			log.Error(condErr, "error recording OBC conditions")
+		}
+	}()
+
+	options, err := c.boundBucketOptions(ctx, obc, ob, class)
+	if err != nil {
+		return true, err
+	}
+
+	log.V(1).Info("updating", "bucket", options.BucketName)
+	callCtx, cancel := c.callContext(ctx)
+	start := time.Now()
+	updated, err := updater.Update(callCtx, ob.DeepCopy(), options)
+	c.metrics.observeCall(operationUpdate, start, err)
+	cancel()
+	if err != nil {
+		return true, fmt.Errorf("error updating bucket: %w", err)
+	}
+
+	// Rewrite the Secret and ConfigMap if the provisioner returned new connection details. The
+	// OB's Authentication is never persisted, so it is only set if the provisioner returned it.
+	if updated != nil && updated.Spec.Connection != nil {
+		if updated.Spec.Authentication != nil {
+			ep := updated.Spec.Endpoint
+			if ep == nil {
+				ep = ob.Spec.Endpoint
+			}
+			if err = createOrUpdateSecret(ctx, obc, class, updated.Spec.Authentication, ep, c.labelsFor(class.Provisioner), c.clientset); err != nil {
+				return true, fmt.Errorf("error updating secret for OBC: %w", err)
+			}
+			ob.Status.Credentials = credentialsStatus(updated.Spec.Authentication)
+		}
+		if updated.Spec.Endpoint != nil {
+			if err = createOrUpdateConfigMap(ctx, obc, class, updated.Spec.Endpoint, c.labelsFor(class.Provisioner), c.clientset); err != nil {
+				return true, fmt.Errorf("error updating configmap for OBC: %w", err)
+			}
+		}
+		ob.Spec.Connection = updated.Spec.Connection
+		if ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Update(ctx, ob, metav1.UpdateOptions{}); err != nil {
+			return true, fmt.Errorf("error updating OB: %v", err)
+		}
+	}
+
+	cond := newCondition(v1alpha1.ConditionConfigApplied, metav1.ConditionTrue, v1alpha1.ReasonUpdated, "additionalConfig applied to the bucket")
+	ob.Status.AppliedAdditionalConfig = obc.Spec.AdditionalConfig
+	setBucketConditions(ob, cond)
+	if ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(ctx, ob, metav1.UpdateOptions{}); err != nil {
+		return true, fmt.Errorf("error updating OB status: %v", err)
+	}
+	c.recorder.Event(ob, corev1.EventTypeNormal, reasonUpdated, "additionalConfig applied to the bucket")
+	c.requeueForRefresh(ctx, key, ob.Status.Credentials)
+
+	// the claim is set back to Bound, in case a previous update failed permanently
+	if obc, err = updateObjectBucketClaimPhase(ctx, c.libClientset, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound, cond); err != nil {
+		return true, fmt.Errorf("error updating OBC %q's status to %q: %v", obc.Name, v1alpha1.ObjectBucketClaimStatusPhaseBound, err)
+	}
+	c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonUpdated, "additionalConfig applied to bucket %q", obc.Spec.BucketName)
+	return true, nil
+}
+
+// handleRefreshClaim refreshes the temporary credentials of a bound claim which are due to expire,
+// if the provisioner implements api.Refresher. refreshing is false if the claim is left to the
+// provisioning path.
+func (c *obcController) handleRefreshClaim(ctx context.Context, key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (refreshing bool, err error) {
+	log := logr.FromContextOrDiscard(ctx)
+
+	refresher, ok := unwrapProvisioner(c.provisionerFor(class)).(api.Refresher)
+	if !ok {
+		return false, nil
+	}
+	ob, err := c.objectBucketForClaim(ctx, obc)
+	if err != nil {
+		return true, fmt.Errorf("failed to find ob associated with obc %q: %v", obc.Name, err)
+	}
+	if ob == nil || ob.Status.Phase != v1alpha1.ObjectBucketStatusPhaseBound {
+		return false, nil
+	}
+	at := refreshTime(ob.Status.Credentials, c.refreshWindow)
+	if at.IsZero() || time.Now().Before(at) {
+		return false, nil
+	}
+
+	log.Info("refreshing expiring credentials", "expiration", ob.Status.Credentials.ExpirationTime)
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		c.recorder.Event(obc, corev1.EventTypeWarning, reasonRefreshFailed, err.Error())
+		cond := newCondition(v1alpha1.ConditionCredentialsReady, metav1.ConditionFalse, v1alpha1.ReasonRefreshFailed, err.Error())
+		if condErr := updateObjectBucketClaimConditions(ctx, c.libClientset, obc, cond); condErr != nil {
+			log.Error(condErr, "error recording OBC conditions")
+		}
+	}()
+
+	options, err := c.boundBucketOptions(ctx, obc, ob, class)
+	if err != nil {
+		return true, err
+	}
+
+	callCtx, cancel := c.callContext(ctx)
+	start := time.Now()
+	auth, err := refresher.Refresh(callCtx, ob.DeepCopy(), options)
+	c.metrics.observeCall(operationRefresh, start, err)
+	cancel()
+	if err != nil {
+		return true, fmt.Errorf("error refreshing credentials: %w", err)
+	}
+	if auth == nil {
+		return true, fmt.Errorf("provisioner returned no credentials")
+	}
+
+	if err = createOrUpdateSecret(ctx, obc, class, auth, ob.Spec.Endpoint, c.labelsFor(class.Provisioner), c.clientset); err != nil {
+		return true, fmt.Errorf("error updating secret for OBC: %w", err)
+	}
+
+	msg := fmt.Sprintf("credentials refreshed in Secret %q", composeSecretName(obc))
+	cond := newCondition(v1alpha1.ConditionCredentialsReady, metav1.ConditionTrue, v1alpha1.ReasonRefreshed, msg)
+	ob.Status.Credentials = credentialsStatus(auth)
+	setBucketConditions(ob, cond)
+	if ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(ctx, ob, metav1.UpdateOptions{}); err != nil {
+		return true, fmt.Errorf("error updating OB status: %v", err)
+	}
+	if err = updateObjectBucketClaimConditions(ctx, c.libClientset, obc, cond); err != nil {
+		return true, fmt.Errorf("error updating OBC %q's conditions: %v", obc.Name, err)
+	}
+	c.recorder.Event(obc, corev1.EventTypeNormal, reasonRefreshed, msg)
+	c.requeueForRefresh(ctx, key, ob.Status.Credentials)
+	return true, nil
+}
+
+// handleRotateClaim rotates the credentials of a bound claim if the provisioner implements
+// api.CredentialRotator and a rotation was requested through the claim's annotation or is due
+// according to the storage class's rotation interval. After the overlap window, the previous
+// credentials are revoked. rotating is false if the claim is left to the provisioning path.
+func (c *obcController) handleRotateClaim(ctx context.Context, key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (rotating bool, err error) {
+	rotator, ok := unwrapProvisioner(c.provisionerFor(class)).(api.CredentialRotator)
+	if !ok {
+		return false, nil
+	}
+	ob, err := c.objectBucketForClaim(ctx, obc)
+	if err != nil {
+		return true, fmt.Errorf("failed to find ob associated with obc %q: %v", obc.Name, err)
+	}
+	if ob == nil || ob.Status.Phase != v1alpha1.ObjectBucketStatusPhaseBound {
+		return false, nil
+	}
+	interval, overlap, err := rotationParameters(class)
+	if err != nil {
+		return true, err
+	}
+
+	rotation := ob.Status.Rotation
+	if rotation == nil {
+		rotation = &v1alpha1.CredentialRotationStatus{}
+	}
+	// a bucket which was never rotated is due one interval after it was created
+	lastRotation := ob.CreationTimestamp.Time
+	if rotation.LastRotationTime != nil {
+		lastRotation = rotation.LastRotationTime.Time
+	}
+	request := obc.Annotations[v1alpha1.RotateCredentialsAnnotation]
+	now := time.Now()
+
+	switch {
+	case rotation.PendingRevocation != nil:
+		// a requested rotation waits for the previous credentials to be revoked
+		if revokeTime := rotation.PendingRevocation.RevokeTime.Time; now.Before(revokeTime) {
+			c.queue.AddAfter(key, revokeTime.Sub(now))
+			return false, nil
+		}
+		return true, c.revokePreviousCredentials(ctx, obc, ob, class, rotator)
+	case request != "" && request != rotation.LastRequest:
+	case interval > 0 && !now.Before(lastRotation.Add(interval)):
+	default:
+		if interval > 0 {
+			c.queue.AddAfter(key, lastRotation.Add(interval).Sub(now))
+		}
+		return false, nil
+	}
+
+	// A missing Secret is restored by the provisioning path before its credentials are rotated.
+	secret, err := secretForClaim(ctx, obc, c.clientset)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return true, fmt.Errorf("error getting secret for OBC: %v", err)
+	}
+	mapping, err := secretKeyMapping(obc, class)
+	if err != nil {
+		return true, err
+	}
+	previousKeyID := secretValue(secret, mappedKey(v1alpha1.AwsKeyField, mapping))
+	return true, c.rotateCredentials(ctx, key, obc, ob, class, rotator, previousKeyID, overlap)
+}
+
+// rotateCredentials writes new credentials to the claim's Secret and records the previous access
+// key for revocation once the overlap window has passed.
+func (c *obcController) rotateCredentials(ctx context.Context, key string, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, class *storagev1.StorageClass, rotator api.CredentialRotator, previousKeyID string, overlap time.Duration) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
+	log.Info("rotating credentials")
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		c.recorder.Event(obc, corev1.EventTypeWarning, reasonRotationFailed, err.Error())
+		cond := newCondition(v1alpha1.ConditionCredentialsReady, metav1.ConditionFalse, v1alpha1.ReasonRotationFailed, err.Error())
+		if condErr := updateObjectBucketClaimConditions(ctx, c.libClientset, obc, cond); condErr != nil {
+			log.Error(condErr, "error recording OBC conditions")
+		}
+	}()
+
+	options, err := c.boundBucketOptions(ctx, obc, ob, class)
+	if err != nil {
+		return err
+	}
+	callCtx, cancel := c.callContext(ctx)
+	start := time.Now()
+	auth, err := rotator.RotateCredentials(callCtx, ob.DeepCopy(), options)
+	c.metrics.observeCall(operationRotate, start, err)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("error rotating credentials: %w", err)
+	}
+	if auth == nil {
+		return fmt.Errorf("provisioner returned no credentials")
+	}
+	if err = createOrUpdateSecret(ctx, obc, class, auth, ob.Spec.Endpoint, c.labelsFor(class.Provisioner), c.clientset); err != nil {
+		return fmt.Errorf("error updating secret for OBC: %w", err)
+	}
+
+	now := metav1.Now()
+	rotation := &v1alpha1.CredentialRotationStatus{
+		LastRotationTime: &now,
+		LastRequest:      obc.Annotations[v1alpha1.RotateCredentialsAnnotation],
+	}
+	if previousKeyID != "" && (auth.AccessKeys == nil || auth.AccessKeys.AccessKeyID != previousKeyID) {
+		rotation.PendingRevocation = &v1alpha1.PendingRevocation{
+			AccessKeyID: previousKeyID,
+			RevokeTime:  metav1.NewTime(now.Add(overlap)),
+		}
+	}
+	msg := fmt.Sprintf("credentials rotated in Secret %q", composeSecretName(obc))
+	cond := newCondition(v1alpha1.ConditionCredentialsReady, metav1.ConditionTrue, v1alpha1.ReasonRotated, msg)
+	ob.Status.Rotation = rotation
+	ob.Status.Credentials = credentialsStatus(auth)
+	setBucketConditions(ob, cond)
+	if ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(ctx, ob, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating OB status: %v", err)
+	}
+	if err = updateObjectBucketClaimConditions(ctx, c.libClientset, obc, cond); err != nil {
+		return fmt.Errorf("error updating OBC %q's conditions: %v", obc.Name, err)
+	}
+	c.recorder.Event(obc, corev1.EventTypeNormal, reasonRotated, msg)
+	if rotation.PendingRevocation != nil {
+		c.queue.AddAfter(key, overlap)
+	}
+	c.requeueForRefresh(ctx, key, ob.Status.Credentials)
+	return nil
+}
+
+// revokePreviousCredentials revokes the access key replaced by the last rotation.
+func (c *obcController) revokePreviousCredentials(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, class *storagev1.StorageClass, rotator api.CredentialRotator) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
+	keyID := ob.Status.Rotation.PendingRevocation.AccessKeyID
+	log.Info("revoking previous credentials", "accessKeyID", keyID)
+
+	options, err := c.boundBucketOptions(ctx, obc, ob, class)
+	if err != nil {
+		return err
+	}
+	callCtx, cancel := c.callContext(ctx)
+	start := time.Now()
+	err = rotator.RevokeCredentials(callCtx, ob.DeepCopy(), options, keyID)
+	c.metrics.observeCall(operationRevokeCredentials, start, err)
+	cancel()
+	if err != nil {
+		c.recorder.Event(obc, corev1.EventTypeWarning, reasonCredentialsRevokeFailed, err.Error())
+		return fmt.Errorf("error revoking previous credentials: %w", err)
+	}
+
+	ob.Status.Rotation.PendingRevocation = nil
+	if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(ctx, ob, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating OB status: %v", err)
+	}
+	c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonCredentialsRevoked, "revoked previous access key %q", keyID)
+	return nil
+}
+
+// boundBucketOptions returns the options passed to the provisioner for operations on the bound
+// bucket of a claim.
+func (c *obcController) boundBucketOptions(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, class *storagev1.StorageClass) (*api.BucketOptions, error) {
+	reclaimPolicy, err := c.reclaimPolicyFor(class)
+	if err != nil {
+		return nil, err
+	}
+	callCtx, cancel := c.callContext(ctx)
+	userID, err := c.provisionerFor(class).GenerateUserID(callCtx, obc, ob)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user id for use as idempotency key: %v", err)
+	}
+	return &api.BucketOptions{
+		ReclaimPolicy:     reclaimPolicy,
+		BucketName:        obc.Spec.BucketName,
+		UserID:            userID,
+		ObjectBucketClaim: obc.DeepCopy(),
+		Parameters:        class.Parameters,
+		Logger:            logr.FromContextOrDiscard(ctx),
+	}, nil
+}
+
+// requeueForRefresh re-queues the claim for when its temporary credentials are due to be
+// refreshed. Claims without temporary credentials are not re-queued.
+func (c *obcController) requeueForRefresh(ctx context.Context, key string, credentials *v1alpha1.CredentialsStatus) {
+	at := refreshTime(credentials, c.refreshWindow)
+	if at.IsZero() {
+		return
+	}
+	delay := time.Until(at)
+	logr.FromContextOrDiscard(ctx).V(1).Info("re-queueing claim to refresh credentials", "after", delay.String())
+	c.queue.AddAfter(key, delay)
+}
+
 // Delete or Revoke access to bucket defined by passed-in key and obc.
-func (c *obcController) handleDeleteClaim(key string, obc *v1alpha1.ObjectBucketClaim) error {
+func (c *obcController) handleDeleteClaim(ctx context.Context, key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) error {
+	log := logr.FromContextOrDiscard(ctx)
 	// Call `Delete` for new (greenfield) buckets with reclaimPolicy == "Delete".
-	// Call `Revoke` for new buckets with reclaimPolicy != "Delete".
+	// Call `Erase` or `Archive`, then `Revoke`, for new buckets with reclaimPolicy == "Erase" or "Archive".
+	// Call `Suspend` for new buckets with reclaimPolicy == "Suspend".
+	// Call `Revoke` for new buckets with any other reclaimPolicy.
 	// Call `Revoke` for existing (brownfield) buckets regardless of reclaimPolicy.
 
 	log.Info("syncing obc deletion")
 
-	ob, cm, secret, errs := c.getExistingResourcesFromKey(key)
+	ob, cm, secret, errs := c.getExistingResourcesFromKey(ctx, key, obc)
 	if len(errs) > 0 {
 		return fmt.Errorf("error getting resources: %v", errs)
 	}
@@ -439,7 +1244,7 @@ func (c *obcController) handleDeleteClaim(key string, obc *v1alpha1.ObjectBucket
 	// and/or cm != nil we can delete them
 	if ob == nil {
 		log.Error(nil, "nil ObjectBucket, assuming it has been deleted")
-		return c.deleteResources(nil, cm, secret, obc)
+		return c.deleteResources(ctx, nil, cm, secret, obc)
 	}
 
 	if ob.Spec.ReclaimPolicy == nil {
@@ -449,33 +1254,156 @@ func (c *obcController) handleDeleteClaim(key string, obc *v1alpha1.ObjectBucket
 
 	// call Delete or Revoke and then delete generated k8s resources
 	// Note: if Delete or Revoke return err then we do not try to delete resources
-	ob, err := updateObjectBucketPhase(c.libClientset, ob, v1alpha1.ObjectBucketClaimStatusPhaseReleased)
+	ob, err := updateObjectBucketPhase(ctx, c.libClientset, ob, v1alpha1.ObjectBucketClaimStatusPhaseReleased)
 	if err != nil {
 		return err
 	}
-
-	// decide whether Delete or Revoke is called
-	if isNewBucketByObjectBucket(c.clientset, ob) && *ob.Spec.ReclaimPolicy == corev1.PersistentVolumeReclaimDelete {
-		if err = c.provisioner.Delete(ob); err != nil {
+	c.recorder.Eventf(ob, corev1.EventTypeNormal, reasonReleased, "claim %q deleted", key)
+
+	// decide whether Delete, Revoke or the provisioner's method of a library defined reclaim
+	// policy is called
+	isNewBucket := isNewBucketByObjectBucket(ctx, c.clientset, ob)
+	policy := *ob.Spec.ReclaimPolicy
+	suspended := isNewBucket && policy == v1alpha1.ReclaimPolicySuspend
+	if isNewBucket && policy == corev1.PersistentVolumeReclaimDelete {
+		callCtx, cancel := c.callContext(ctx)
+		start := time.Now()
+		err = c.provisionerFor(class).Delete(callCtx, ob)
+		c.metrics.observeCall(operationDelete, start, err)
+		cancel()
+		if err != nil {
 			// Do not proceed to deleting the ObjectBucket if the deprovisioning fails for bookkeeping purposes
-			return fmt.Errorf("provisioner error deleting bucket %v", err)
+			err = fmt.Errorf("provisioner error deleting bucket %w", err)
+			c.recorder.Event(obc, corev1.EventTypeWarning, reasonDeleteFailed, err.Error())
+			c.recorder.Event(ob, corev1.EventTypeWarning, reasonDeleteFailed, err.Error())
+			c.setDeletionBlocked(ctx, obc, ob, v1alpha1.ReasonDeleteFailed, err)
+			return c.failObjectBucketIfPermanent(ctx, ob, err)
 		}
+		c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonDeleted, "deleted bucket %q", obc.Spec.BucketName)
 	} else {
-		if err = c.provisioner.Revoke(ob); err != nil {
-			return fmt.Errorf("provisioner error revoking access to bucket %v", err)
+		if _, ok := libraryReclaimPolicies[policy]; ok && isNewBucket {
+			if err = c.reclaimBucket(ctx, ob, class, policy); err != nil {
+				err = fmt.Errorf("provisioner error reclaiming bucket with policy %q: %w", policy, err)
+				c.recorder.Event(obc, corev1.EventTypeWarning, reasonReclaimFailed, err.Error())
+				c.recorder.Event(ob, corev1.EventTypeWarning, reasonReclaimFailed, err.Error())
+				c.setDeletionBlocked(ctx, obc, ob, v1alpha1.ReasonReclaimFailed, err)
+				return c.failObjectBucketIfPermanent(ctx, ob, err)
+			}
+			c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonReclaimed, "reclaimed bucket %q with policy %q", obc.Spec.BucketName, policy)
+		}
+		// suspended buckets keep their credentials
+		if !suspended {
+			callCtx, cancel := c.callContext(ctx)
+			start := time.Now()
+			err = c.provisionerFor(class).Revoke(callCtx, ob)
+			c.metrics.observeCall(operationRevoke, start, err)
+			cancel()
+			if err != nil {
+				err = fmt.Errorf("provisioner error revoking access to bucket %w", err)
+				c.recorder.Event(obc, corev1.EventTypeWarning, reasonRevokeFailed, err.Error())
+				c.recorder.Event(ob, corev1.EventTypeWarning, reasonRevokeFailed, err.Error())
+				c.setDeletionBlocked(ctx, obc, ob, v1alpha1.ReasonRevokeFailed, err)
+				return c.failObjectBucketIfPermanent(ctx, ob, err)
+			}
+			c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonRevoked, "revoked access to bucket %q", obc.Spec.BucketName)
 		}
 	}
 
-	return c.deleteResources(ob, cm, secret, obc)
+	// The OB and Secret of a suspended bucket are kept, since its credentials are not recorded
+	// anywhere else.
+	if suspended {
+		err = c.retainResources(ctx, ob, cm, secret, obc)
+	} else {
+		err = c.deleteResources(ctx, ob, cm, secret, obc)
+	}
+	if err != nil {
+		c.setDeletionBlocked(ctx, obc, nil, v1alpha1.ReasonReleaseFailed, err)
+		return err
+	}
+	return nil
+}
+
+// reclaimBucket carries out a library defined reclaim policy through the optional interface
+// implemented by the provisioner. A provisioner which does not implement the policy fails the
+// reclaim permanently, since retrying cannot succeed.
+func (c *obcController) reclaimBucket(ctx context.Context, ob *v1alpha1.ObjectBucket, class *storagev1.StorageClass, policy corev1.PersistentVolumeReclaimPolicy) error {
+	reclaim := reclaimFunc(unwrapProvisioner(c.provisionerFor(class)), policy)
+	if reclaim == nil {
+		return liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+			fmt.Sprintf("provisioner %q does not implement reclaim policy %q", class.Provisioner, policy))
+	}
+	callCtx, cancel := c.callContext(ctx)
+	defer cancel()
+	start := time.Now()
+	err := reclaim(callCtx, ob)
+	c.metrics.observeCall(libraryReclaimPolicies[policy], start, err)
+	return err
+}
+
+// reclaimPolicyFor returns the reclaim policy of the new buckets of the class. A library defined
+// reclaim policy which the class's provisioner does not implement fails the claim, rather than
+// its deletion.
+func (c *obcController) reclaimPolicyFor(class *storagev1.StorageClass) (*corev1.PersistentVolumeReclaimPolicy, error) {
+	policy, err := reclaimPolicyForClass(class)
+	if err != nil || policy == nil || !isNewBucketByStorageClass(class) {
+		return policy, err
+	}
+	if _, ok := libraryReclaimPolicies[*policy]; ok && reclaimFunc(unwrapProvisioner(c.provisionerFor(class)), *policy) == nil {
+		return nil, liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+			fmt.Sprintf("provisioner %q does not implement reclaim policy %q", class.Provisioner, *policy))
+	}
+	return policy, nil
+}
+
+// setDeletionBlocked records on the OBC, and the OB if given, that cleanup cannot proceed. Errors
+// are only logged since the caller is already handling a failure.
+func (c *obcController) setDeletionBlocked(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, reason string, cause error) {
+	log := logr.FromContextOrDiscard(ctx)
+	cond := newCondition(v1alpha1.ConditionDeletionBlocked, metav1.ConditionTrue, reason, cause.Error())
+	if err := updateObjectBucketClaimConditions(ctx, c.libClientset, obc, cond); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "error recording OBC conditions")
+	}
+	if ob == nil {
+		return
+	}
+	if err := updateObjectBucketConditions(ctx, c.libClientset, ob, cond); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "error recording OB conditions")
+	}
+}
+
+// failObjectBucketIfPermanent moves the OB to the Failed phase if err is a permanent provisioner
+// error, in which case cleanup is not retried and nil is returned. Cleanup of a Failed OB
+// requires manual intervention. Any other error is returned as is so the request is re-queued.
+func (c *obcController) failObjectBucketIfPermanent(ctx context.Context, ob *v1alpha1.ObjectBucket, err error) error {
+	log := logr.FromContextOrDiscard(ctx)
+	if !liberrors.IsPermanent(err) {
+		return err
+	}
+	log.Error(err, "cleanup failed permanently", "ob", ob.Name)
+	if _, updateErr := updateObjectBucketPhase(ctx, c.libClientset, ob, v1alpha1.ObjectBucketStatusPhaseFailed); updateErr != nil {
+		return updateErr
+	}
+	c.recorder.Eventf(ob, corev1.EventTypeWarning, reasonFailed, "cleanup failed permanently: %v", err)
+	return nil
+}
+
+// callContext returns the context of a single call to the provisioner, which is bounded by the
+// configured call timeout.
+func (c *obcController) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.callTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.callTimeout)
 }
 
 func (c *obcController) supportedProvisioner(provisioner string) bool {
-	return provisioner == c.provisionerName
+	_, ok := c.provisioners[provisioner]
+	return ok
 }
 
 // trim the errors resulting from objects not being found
-func (c *obcController) getExistingResourcesFromKey(key string) (*v1alpha1.ObjectBucket, *corev1.ConfigMap, *corev1.Secret, []error) {
-	ob, cm, secret, errs := c.getResourcesFromKey(key)
+func (c *obcController) getExistingResourcesFromKey(ctx context.Context, key string, obc *v1alpha1.ObjectBucketClaim) (*v1alpha1.ObjectBucket, *corev1.ConfigMap, *corev1.Secret, []error) {
+	ob, cm, secret, errs := c.getResourcesFromKey(ctx, key, obc)
 	for i := len(errs) - 1; i >= 0; i-- {
 		if errors.IsNotFound(errs[i]) {
 			errs = append(errs[:i], errs[i+1:]...)
@@ -484,10 +1412,10 @@ func (c *obcController) getExistingResourcesFromKey(key string) (*v1alpha1.Objec
 	return ob, cm, secret, errs
 }
 
-// Gathers resources by names derived from key.
+// Gathers resources by names derived from key and the claim's spec.
 // Returns pointers to those resources if they exist, nil otherwise and an slice of errors who's
 // len() == n errors. If no errors occur, len() is 0.
-func (c *obcController) getResourcesFromKey(key string) (ob *v1alpha1.ObjectBucket, cm *corev1.ConfigMap, sec *corev1.Secret, errs []error) {
+func (c *obcController) getResourcesFromKey(ctx context.Context, key string, obc *v1alpha1.ObjectBucketClaim) (ob *v1alpha1.ObjectBucket, cm *corev1.ConfigMap, sec *corev1.Secret, errs []error) {
 
 	var err error
 	// The cap(errs) must be large enough to encapsulate errors returned by all 3 *ForClaimKey funcs
@@ -498,11 +1426,11 @@ func (c *obcController) getResourcesFromKey(key string) (ob *v1alpha1.ObjectBuck
 		}
 	}
 
-	ob, err = c.objectBucketForClaimKey(key)
+	ob, err = c.objectBucketForClaim(ctx, obc)
 	groupErrors(err)
-	cm, err = configMapForClaimKey(key, c.clientset)
+	cm, err = configMapForClaim(ctx, obc, c.clientset)
 	groupErrors(err)
-	sec, err = secretForClaimKey(key, c.clientset)
+	sec, err = secretForClaim(ctx, obc, c.clientset)
 	groupErrors(err)
 
 	return
@@ -516,29 +1444,64 @@ func (c *obcController) getResourcesFromKey(key string) (ob *v1alpha1.ObjectBuck
 // is to remove the finalizer on the OBC so it too will be garbage collected.
 // Returns err if we can't delete one or more of the resources, the final returned error being
 // somewhat arbitrary.
-func (c *obcController) deleteResources(ob *v1alpha1.ObjectBucket, cm *corev1.ConfigMap, s *corev1.Secret, obc *v1alpha1.ObjectBucketClaim) (err error) {
+func (c *obcController) deleteResources(ctx context.Context, ob *v1alpha1.ObjectBucket, cm *corev1.ConfigMap, s *corev1.Secret, obc *v1alpha1.ObjectBucketClaim) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
 
-	if delErr := deleteObjectBucket(ob, c.libClientset); delErr != nil {
+	if delErr := deleteObjectBucket(ctx, ob, c.libClientset); delErr != nil {
 		log.Error(delErr, "error deleting objectBucket", ob.Name)
+		c.recorder.Eventf(ob, corev1.EventTypeWarning, reasonReleaseFailed, "error deleting ObjectBucket: %v", delErr)
 		err = delErr
 	}
-	if delErr := releaseSecret(s, c.clientset); delErr != nil {
+	if delErr := releaseSecret(ctx, s, c.clientset); delErr != nil {
 		log.Error(delErr, "error releasing secret")
+		c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonReleaseFailed, "error releasing Secret: %v", delErr)
 		err = delErr
 	}
-	if delErr := releaseConfigMap(cm, c.clientset); delErr != nil {
+	if delErr := releaseConfigMap(ctx, cm, c.clientset); delErr != nil {
 		log.Error(delErr, "error releasing configMap")
+		c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonReleaseFailed, "error releasing ConfigMap: %v", delErr)
 		err = delErr
 	}
-	if delErr := releaseOBC(obc, c.libClientset); delErr != nil {
+	if delErr := releaseOBC(ctx, obc, c.libClientset); delErr != nil {
 		log.Error(delErr, "error releasing obc")
+		c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonReleaseFailed, "error releasing ObjectBucketClaim: %v", delErr)
 		err = delErr
 	}
 	return err
 }
 
+// retainResources is like deleteResources, but keeps the OB and the Secret of a suspended bucket.
+// The OB and Secret are released rather than deleted, and the Secret is no longer owned by the
+// claim, so that they outlive it.
+func (c *obcController) retainResources(ctx context.Context, ob *v1alpha1.ObjectBucket, cm *corev1.ConfigMap, s *corev1.Secret, obc *v1alpha1.ObjectBucketClaim) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
+
+	if relErr := releaseObjectBucket(ctx, ob, c.libClientset); relErr != nil {
+		log.Error(relErr, "error releasing objectBucket", "name", ob.Name)
+		c.recorder.Eventf(ob, corev1.EventTypeWarning, reasonReleaseFailed, "error releasing ObjectBucket: %v", relErr)
+		err = relErr
+	}
+	if relErr := orphanSecret(ctx, s, c.clientset); relErr != nil {
+		log.Error(relErr, "error releasing secret")
+		c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonReleaseFailed, "error releasing Secret: %v", relErr)
+		err = relErr
+	}
+	if relErr := releaseConfigMap(ctx, cm, c.clientset); relErr != nil {
+		log.Error(relErr, "error releasing configMap")
+		c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonReleaseFailed, "error releasing ConfigMap: %v", relErr)
+		err = relErr
+	}
+	if relErr := releaseOBC(ctx, obc, c.libClientset); relErr != nil {
+		log.Error(relErr, "error releasing obc")
+		c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonReleaseFailed, "error releasing ObjectBucketClaim: %v", relErr)
+		err = relErr
+	}
+	return err
+}
+
 // Add finalizer and labels to the OBC.
-func (c *obcController) setOBCMetaFields(obc *v1alpha1.ObjectBucketClaim) (*v1alpha1.ObjectBucketClaim, error) {
+func (c *obcController) setOBCMetaFields(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (*v1alpha1.ObjectBucketClaim, error) {
+	log := logr.FromContextOrDiscard(ctx)
 	clib := c.libClientset
 
 	// Do not make changes directly to the obc used as input. If the update fails, we should return
@@ -547,10 +1510,10 @@ func (c *obcController) setOBCMetaFields(obc *v1alpha1.ObjectBucketClaim) (*v1al
 	updateOBC := obc.DeepCopy()
 
 	addFinalizers(updateOBC, []string{finalizer})
-	addLabels(updateOBC, c.provisionerLabels)
+	addLabels(ctx, updateOBC, c.labelsFor(class.Provisioner))
 
-	logD.Info("updating OBC metadata")
-	obcUpdated, err := updateClaim(clib, updateOBC)
+	log.V(1).Info("updating OBC metadata")
+	obcUpdated, err := updateClaim(ctx, clib, updateOBC)
 	if err != nil {
 		return obc, fmt.Errorf("error configuring obc metadata: %v", err)
 	}
@@ -558,20 +1521,40 @@ func (c *obcController) setOBCMetaFields(obc *v1alpha1.ObjectBucketClaim) (*v1al
 	return obcUpdated, nil
 }
 
-func (c *obcController) objectBucketForClaimKey(key string) (*v1alpha1.ObjectBucket, error) {
-	logD.Info("getting objectBucket for key", "key", key)
-	name, err := objectBucketNameFromClaimKey(key)
-	if err != nil {
-		return nil, err
+// objectBucketForClaim returns the OB bound to the claim, or nil if there is none. The OB is looked
+// up by the name recorded in the claim's spec or, before the claim is bound, by the name derived
+// from its UID. OBs of claims bound by earlier versions of the library which did not record the
+// name are found through the claim index. OBs whose claimRef refers to another claim are ignored.
+func (c *obcController) objectBucketForClaim(ctx context.Context, obc *v1alpha1.ObjectBucketClaim) (*v1alpha1.ObjectBucket, error) {
+	log := logr.FromContextOrDiscard(ctx)
+	name := obc.Spec.ObjectBucketName
+	if name == "" {
+		name = objectBucketNameForClaim(obc)
 	}
-	ob, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), name, metav1.GetOptions{})
+	log.V(1).Info("getting objectBucket for claim", "name", name)
+	ob, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case err == nil && claimRefMatches(ob.Spec.ClaimRef, obc):
+		return ob, nil
+	case err == nil:
+		log.Info("ignoring ObjectBucket bound to another claim", "name", name, "claimRef", ob.Spec.ClaimRef)
+	case !errors.IsNotFound(err):
+		return nil, fmt.Errorf("failed to get ob %q: %v", name, err)
+	}
+
+	objs, err := c.obIndexer.ByIndex(claimIndex, obc.Namespace+"/"+obc.Name)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to look up ob of claim: %v", err)
+	}
+	for _, obj := range objs {
+		if ob, ok := obj.(*v1alpha1.ObjectBucket); ok && ob.Name != name && claimRefMatches(ob.Spec.ClaimRef, obc) {
+			return ob.DeepCopy(), nil
+		}
 	}
-	return ob, nil
+	return nil, nil
 }
 
-func updateSupported(old, new *v1alpha1.ObjectBucketClaim) bool {
+func updateSupported(log logr.Logger, old, new *v1alpha1.ObjectBucketClaim) bool {
 
 	// Deletiong stamp is set, so return true so that it will be added
 	// to queue for deletion
@@ -579,10 +1562,20 @@ func updateSupported(old, new *v1alpha1.ObjectBucketClaim) bool {
 		return true
 	}
 
+	// A new credentials rotation request is handled even though the spec is unchanged
+	if new.Annotations[v1alpha1.RotateCredentialsAnnotation] != old.Annotations[v1alpha1.RotateCredentialsAnnotation] {
+		return true
+	}
+
 	// The only field supported for update is obc.spec.additionalConfig
 	if reflect.DeepEqual(new.Spec, old.Spec) {
 		return false
 	}
+	// A claim which failed permanently is retried on any change to its spec, e.g. a bucket name
+	// set in place of a rejected one.
+	if new.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+		return true
+	}
 	// create copy of old spec, and set the new spec's additionalConfig on it
 	oldspec := old.Spec.DeepCopy()
 	oldspec.AdditionalConfig = new.Spec.AdditionalConfig
@@ -0,0 +1,1823 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/google/go-cmp/cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	externalFake "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned/fake"
+	informers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/informers/externalversions"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+	liberrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
+)
+
+func newTestController(p api.Provisioner, kubeObjs []runtime.Object, libObjs []runtime.Object) (*obcController, *record.FakeRecorder) {
+	client := fake.NewSimpleClientset(kubeObjs...)
+	libClient := externalFake.NewSimpleClientset(libObjs...)
+	factory := informers.NewSharedInformerFactory(libClient, 0)
+	recorder := record.NewFakeRecorder(100)
+
+	c := NewController(
+		provisionerName,
+		p,
+		client,
+		libClient,
+		factory.Objectbucket().V1alpha1().ObjectBucketClaims(),
+		factory.Objectbucket().V1alpha1().ObjectBuckets(),
+		WithEventRecorder(recorder))
+	return c, recorder
+}
+
+func TestNewController_defaultRecorder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	libClient := externalFake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(libClient, 0)
+
+	// the constructor's signature is unchanged from earlier versions, which had no recorder
+	c := NewController(provisionerName, &fakeProvisioner{}, client, libClient,
+		factory.Objectbucket().V1alpha1().ObjectBucketClaims(),
+		factory.Objectbucket().V1alpha1().ObjectBuckets())
+	if c.recorder == nil {
+		t.Error("NewController() did not default the event recorder")
+	}
+}
+
+func newTestStorageClass() *storagev1.StorageClass {
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	return &storagev1.StorageClass{
+		ObjectMeta:    metav1.ObjectMeta{Name: className},
+		Provisioner:   provisionerName,
+		ReclaimPolicy: &reclaimPolicy,
+	}
+}
+
+func newTestClaim() *v1alpha1.ObjectBucketClaim {
+	return &v1alpha1.ObjectBucketClaim{
+		ObjectMeta: objMeta,
+		Spec: v1alpha1.ObjectBucketClaimSpec{
+			StorageClassName:   className,
+			GenerateBucketName: "test-bucket",
+		},
+	}
+}
+
+// objectBucketForKey returns the OB bound to the claim of key.
+func objectBucketForKey(c *obcController, key string) (*v1alpha1.ObjectBucket, error) {
+	obc, err := claimForKey(context.TODO(), key, c.libClientset)
+	if err != nil {
+		return nil, err
+	}
+	ob, err := c.objectBucketForClaim(context.TODO(), obc)
+	if err == nil && ob == nil {
+		err = fmt.Errorf("no ObjectBucket bound to claim %q", key)
+	}
+	return ob, err
+}
+
+// drainEvents returns the "<type> <reason>" prefix of every event recorded so far.
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case e := <-recorder.Events:
+			fields := strings.Fields(e)
+			events = append(events, strings.Join(fields[:2], " "))
+		default:
+			return events
+		}
+	}
+}
+
+func Test_obcController_syncHandler_events(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name        string
+		provisioner api.Provisioner
+		kubeObjs    []runtime.Object
+		wantErr     bool
+		wantEvents  []string
+	}{
+		{
+			name:        "missing storage class",
+			provisioner: &fakeProvisioner{},
+			wantErr:     true,
+			wantEvents: []string{
+				corev1.EventTypeWarning + " " + reasonStorageClassLookupFailed,
+			},
+		},
+		{
+			name:        "successful provisioning",
+			provisioner: &fakeProvisioner{},
+			kubeObjs:    []runtime.Object{newTestStorageClass()},
+			wantEvents: []string{
+				corev1.EventTypeNormal + " " + reasonPending,
+				corev1.EventTypeNormal + " " + reasonProvisioning,
+				corev1.EventTypeNormal + " " + reasonBound,
+				corev1.EventTypeNormal + " " + reasonBound,
+			},
+		},
+		{
+			name:        "provisioner error",
+			provisioner: &fakeProvisioner{err: fmt.Errorf("backend unavailable")},
+			kubeObjs:    []runtime.Object{newTestStorageClass()},
+			wantErr:     true,
+			wantEvents: []string{
+				corev1.EventTypeNormal + " " + reasonPending,
+				corev1.EventTypeNormal + " " + reasonProvisioning,
+				corev1.EventTypeWarning + " " + reasonProvisioningFailed,
+			},
+		},
+		{
+			name:        "permanent provisioner error",
+			provisioner: &fakeProvisioner{err: liberrors.NewPermanentError(liberrors.ReasonInvalidParameters, "bad parameter")},
+			kubeObjs:    []runtime.Object{newTestStorageClass()},
+			wantErr:     false,
+			wantEvents: []string{
+				corev1.EventTypeNormal + " " + reasonPending,
+				corev1.EventTypeNormal + " " + reasonProvisioning,
+				corev1.EventTypeWarning + " " + reasonProvisioningFailed,
+				corev1.EventTypeWarning + " " + reasonFailed,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, recorder := newTestController(tt.provisioner, tt.kubeObjs, []runtime.Object{newTestClaim()})
+
+			err := c.syncHandler(context.TODO(), key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("syncHandler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := drainEvents(recorder)
+			if strings.Join(got, ",") != strings.Join(tt.wantEvents, ",") {
+				t.Errorf("syncHandler() events = %v, want %v", got, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_conditions(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name        string
+		provisioner api.Provisioner
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		want        map[string]metav1.ConditionStatus
+	}{
+		{
+			name:        "successful provisioning",
+			provisioner: &fakeProvisioner{},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			want: map[string]metav1.ConditionStatus{
+				v1alpha1.ConditionProvisioned:      metav1.ConditionTrue,
+				v1alpha1.ConditionCredentialsReady: metav1.ConditionTrue,
+				v1alpha1.ConditionEndpointReady:    metav1.ConditionTrue,
+			},
+		},
+		{
+			name:        "provisioner error",
+			provisioner: &fakeProvisioner{err: fmt.Errorf("backend unavailable")},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhasePending,
+			want: map[string]metav1.ConditionStatus{
+				v1alpha1.ConditionProvisioned: metav1.ConditionFalse,
+			},
+		},
+		{
+			name:        "permanent provisioner error",
+			provisioner: &fakeProvisioner{err: liberrors.NewPermanentError(liberrors.ReasonQuotaDenied, "quota exceeded")},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			want: map[string]metav1.ConditionStatus{
+				v1alpha1.ConditionProvisioned: metav1.ConditionFalse,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestController(tt.provisioner, []runtime.Object{newTestStorageClass()}, []runtime.Object{newTestClaim()})
+
+			_ = c.syncHandler(context.TODO(), key)
+
+			obc, err := claimForKey(context.TODO(), key, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if obc.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", obc.Status.Phase, tt.wantPhase)
+			}
+			got := make(map[string]metav1.ConditionStatus)
+			for _, cond := range obc.Status.Conditions {
+				got[cond.Type] = cond.Status
+			}
+			if !cmp.Equal(tt.want, got) {
+				t.Errorf(cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_skipsFailedClaim(t *testing.T) {
+	obc := newTestClaim()
+	obc.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseFailed
+	c, recorder := newTestController(&fakeProvisioner{err: fmt.Errorf("should not be called")}, []runtime.Object{newTestStorageClass()}, []runtime.Object{obc})
+
+	if err := c.syncHandler(context.TODO(), testNamespace+"/"+testName); err != nil {
+		t.Errorf("syncHandler() error = %v, want nil", err)
+	}
+	if got := drainEvents(recorder); len(got) != 0 {
+		t.Errorf("syncHandler() events = %v, want none", got)
+	}
+}
+
+func Test_obcController_updateOBC_rotateCredentials(t *testing.T) {
+	c, _ := newTestController(&fakeProvisioner{}, nil, nil)
+	defer c.queue.ShutDown()
+
+	old := newTestClaim()
+	old.ResourceVersion = "1"
+	old.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+	new := old.DeepCopy()
+	new.ResourceVersion = "2"
+	new.Annotations = map[string]string{v1alpha1.RotateCredentialsAnnotation: "1"}
+
+	c.updateOBC(old, new)
+
+	// the key is added rate limited, so it only shows up in the queue after a delay
+	key := make(chan interface{})
+	go func() {
+		k, _ := c.queue.Get()
+		key <- k
+	}()
+	select {
+	case k := <-key:
+		if k != testNamespace+"/"+testName {
+			t.Errorf("queued key = %v, want %s", k, testNamespace+"/"+testName)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("claim was not queued")
+	}
+}
+
+func Test_updateSupported(t *testing.T) {
+	failed := newTestClaim()
+	failed.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseFailed
+	bound := newTestClaim()
+	bound.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+
+	tests := []struct {
+		name   string
+		old    *v1alpha1.ObjectBucketClaim
+		update func(obc *v1alpha1.ObjectBucketClaim)
+		want   bool
+	}{
+		{
+			name:   "unchanged spec",
+			old:    bound,
+			update: func(obc *v1alpha1.ObjectBucketClaim) { obc.Labels = map[string]string{"foo": "bar"} },
+			want:   false,
+		},
+		{
+			name:   "additionalConfig of a bound claim",
+			old:    bound,
+			update: func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.AdditionalConfig = map[string]string{"foo": "bar"} },
+			want:   true,
+		},
+		{
+			name:   "other field of a bound claim",
+			old:    bound,
+			update: func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.BucketName = "my-bucket" },
+			want:   false,
+		},
+		{
+			name: "credentials rotation request of a bound claim",
+			old:  bound,
+			update: func(obc *v1alpha1.ObjectBucketClaim) {
+				obc.Annotations = map[string]string{v1alpha1.RotateCredentialsAnnotation: "1"}
+			},
+			want: true,
+		},
+		{
+			name:   "other field of a failed claim",
+			old:    failed,
+			update: func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.BucketName = "my-bucket" },
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			new := tt.old.DeepCopy()
+			tt.update(new)
+			if got := updateSupported(logr.Discard(), tt.old, new); got != tt.want {
+				t.Errorf("updateSupported() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// loggingProvisioner logs through the logger given in BucketOptions on every Provision call.
+type loggingProvisioner struct {
+	fakeProvisioner
+}
+
+func (p *loggingProvisioner) Provision(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	obc := options.ObjectBucketClaim
+	options.Logger.Info("provisioning", "obc", obc.Namespace+"/"+obc.Name)
+	return p.fakeProvisioner.Provision(options)
+}
+
+func Test_obcController_syncHandler_requestLogger(t *testing.T) {
+	const claims = 10
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	logger := funcr.New(func(prefix, args string) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, args)
+	}, funcr.Options{})
+
+	libObjs := make([]runtime.Object, 0, claims)
+	for i := 0; i < claims; i++ {
+		obc := newTestClaim()
+		obc.Name = fmt.Sprintf("%s-%d", testName, i)
+		libObjs = append(libObjs, obc)
+	}
+	c, _ := newTestController(&loggingProvisioner{}, []runtime.Object{newTestStorageClass()}, libObjs)
+	c.log = logger
+
+	var wg sync.WaitGroup
+	for i := 0; i < claims; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			if err := c.syncHandler(context.TODO(), key); err != nil {
+				t.Errorf("syncHandler(%q) error = %v", key, err)
+			}
+		}(fmt.Sprintf("%s/%s-%d", testNamespace, testName, i))
+	}
+	wg.Wait()
+
+	provisionerLine := regexp.MustCompile(`"msg"="provisioning" "key"="([^"]+)" "obc"="([^"]+)"`)
+	var matched int
+	for _, line := range lines {
+		m := provisionerLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		matched++
+		if m[1] != m[2] {
+			t.Errorf("provisioner log line for OBC %q attributed to key %q", m[2], m[1])
+		}
+	}
+	if matched != claims {
+		t.Errorf("got %d provisioner log lines, want %d", matched, claims)
+	}
+}
+
+// blockingProvisioner blocks in Provision until the call's context is done.
+type blockingProvisioner struct {
+	api.ProvisionerV2
+}
+
+func (p *blockingProvisioner) Provision(ctx context.Context, options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func Test_obcController_syncHandler_cancellation(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		cancelAfter time.Duration
+		wantErr     error
+	}{
+		{
+			name:    "call timeout",
+			opts:    []Option{WithCallTimeout(10 * time.Millisecond)},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:        "shutdown",
+			cancelAfter: 10 * time.Millisecond,
+			wantErr:     context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(newTestStorageClass())
+			libClient := externalFake.NewSimpleClientset(newTestClaim())
+			factory := informers.NewSharedInformerFactory(libClient, 0)
+			p := &blockingProvisioner{ProvisionerV2: api.AdaptProvisioner(&fakeProvisioner{})}
+			o := newOptions(append(tt.opts, WithEventRecorder(record.NewFakeRecorder(100)))...)
+			c := newController(
+				map[string]api.ProvisionerV2{provisionerName: p},
+				client,
+				libClient,
+				factory.Objectbucket().V1alpha1().ObjectBucketClaims(),
+				factory.Objectbucket().V1alpha1().ObjectBuckets(),
+				o)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelAfter > 0 {
+				time.AfterFunc(tt.cancelAfter, cancel)
+			}
+
+			done := make(chan error)
+			go func() { done <- c.syncHandler(ctx, key) }()
+			select {
+			case err := <-done:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("syncHandler() error = %v, want %v", err, tt.wantErr)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("syncHandler() did not return after the provisioner call was aborted")
+			}
+		})
+	}
+}
+
+// updatingProvisioner counts the calls to Provision and Update.
+type updatingProvisioner struct {
+	fakeProvisioner
+	provisioned, updated int
+}
+
+var _ api.Updater = &updatingProvisioner{}
+
+func (p *updatingProvisioner) Provision(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	p.provisioned++
+	return p.fakeProvisioner.Provision(options)
+}
+
+func (p *updatingProvisioner) Update(ctx context.Context, ob *v1alpha1.ObjectBucket, options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	p.updated++
+	return nil, p.err
+}
+
+func Test_obcController_syncHandler_update(t *testing.T) {
+	key := testNamespace + "/" + testName
+	applied := map[string]string{"versioning": "Disabled"}
+
+	tests := []struct {
+		name            string
+		config          map[string]string
+		err             error
+		wantProvisioned int
+		wantUpdated     int
+		wantPhase       v1alpha1.ObjectBucketClaimStatusPhase
+		wantApplied     map[string]string
+	}{
+		{
+			name:            "unchanged config",
+			config:          applied,
+			wantProvisioned: 1,
+			wantPhase:       v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantApplied:     applied,
+		},
+		{
+			name:        "changed config",
+			config:      map[string]string{"versioning": "Enabled"},
+			wantUpdated: 1,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantApplied: map[string]string{"versioning": "Enabled"},
+		},
+		{
+			name:        "permanent update error",
+			config:      map[string]string{"versioning": "Bogus"},
+			err:         liberrors.NewPermanentError(liberrors.ReasonInvalidParameters, "invalid versioning"),
+			wantUpdated: 1,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantApplied: applied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim()
+			obc.Spec.BucketName = "test-bucket"
+			obc.Spec.ObjectBucketName = "obc-" + testNamespace + "-" + testName
+			obc.Spec.AdditionalConfig = tt.config
+			obc.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+			ob := &v1alpha1.ObjectBucket{
+				ObjectMeta: metav1.ObjectMeta{Name: obc.Spec.ObjectBucketName},
+				Spec: v1alpha1.ObjectBucketSpec{
+					StorageClassName: className,
+					ClaimRef:         makeObjectReference(obc),
+					Connection: &v1alpha1.Connection{
+						Endpoint: &v1alpha1.Endpoint{BucketName: obc.Spec.BucketName},
+					},
+				},
+				Status: v1alpha1.ObjectBucketStatus{
+					Phase:                   v1alpha1.ObjectBucketStatusPhaseBound,
+					AppliedAdditionalConfig: applied,
+				},
+			}
+			p := &updatingProvisioner{fakeProvisioner: fakeProvisioner{err: tt.err}}
+			c, _ := newTestController(p, []runtime.Object{newTestStorageClass()}, []runtime.Object{obc, ob})
+
+			_ = c.syncHandler(context.TODO(), key)
+
+			if p.provisioned != tt.wantProvisioned || p.updated != tt.wantUpdated {
+				t.Errorf("Provision called %d times, Update called %d times, want %d and %d", p.provisioned, p.updated, tt.wantProvisioned, tt.wantUpdated)
+			}
+			gotOBC, err := claimForKey(context.TODO(), key, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if gotOBC.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", gotOBC.Status.Phase, tt.wantPhase)
+			}
+			gotOB, err := objectBucketForKey(c, key)
+			if err != nil {
+				t.Fatalf("error getting bucket: %v", err)
+			}
+			if !cmp.Equal(tt.wantApplied, gotOB.Status.AppliedAdditionalConfig) {
+				t.Errorf("appliedAdditionalConfig: %s", cmp.Diff(tt.wantApplied, gotOB.Status.AppliedAdditionalConfig))
+			}
+		})
+	}
+}
+
+type refreshingProvisioner struct {
+	fakeProvisioner
+	provisioned, refreshed int
+}
+
+var _ api.Refresher = &refreshingProvisioner{}
+
+func (p *refreshingProvisioner) Provision(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	p.provisioned++
+	ob, err := p.fakeProvisioner.Provision(options)
+	if err != nil {
+		return nil, err
+	}
+	expiration := metav1.NewTime(time.Now().Add(time.Hour))
+	ob.Spec.Authentication.AccessKeys.SessionToken = "provisioned"
+	ob.Spec.Authentication.ExpirationTime = &expiration
+	return ob, nil
+}
+
+func (p *refreshingProvisioner) Refresh(ctx context.Context, ob *v1alpha1.ObjectBucket, options *api.BucketOptions) (*v1alpha1.Authentication, error) {
+	p.refreshed++
+	expiration := metav1.NewTime(time.Now().Add(2 * time.Hour))
+	return &v1alpha1.Authentication{
+		AccessKeys:     &v1alpha1.AccessKeys{SessionToken: "refreshed"},
+		ExpirationTime: &expiration,
+	}, p.err
+}
+
+func Test_obcController_syncHandler_refresh(t *testing.T) {
+	key := testNamespace + "/" + testName
+	p := &refreshingProvisioner{}
+	c, _ := newTestController(p, []runtime.Object{newTestStorageClass()}, []runtime.Object{newTestClaim()})
+
+	sessionToken := func() string {
+		secret, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("error getting secret: %v", err)
+		}
+		return secret.StringData[v1alpha1.AwsSessionField]
+	}
+
+	// credentials which are not due are left to the provisioning path
+	for i := 0; i < 2; i++ {
+		if err := c.syncHandler(context.TODO(), key); err != nil {
+			t.Fatalf("syncHandler() error = %v", err)
+		}
+	}
+	if p.provisioned != 2 || p.refreshed != 0 {
+		t.Fatalf("Provision called %d times, Refresh called %d times, want 2 and 0", p.provisioned, p.refreshed)
+	}
+	if got := sessionToken(); got != "provisioned" {
+		t.Errorf("session token = %q, want %q", got, "provisioned")
+	}
+	ob, err := objectBucketForKey(c, key)
+	if err != nil {
+		t.Fatalf("error getting bucket: %v", err)
+	}
+	if ob.Status.Credentials == nil || ob.Status.Credentials.ExpirationTime == nil {
+		t.Fatalf("credentials status not recorded: %v", ob.Status.Credentials)
+	}
+
+	// credentials within the refresh window are refreshed
+	issued := metav1.NewTime(time.Now().Add(-time.Hour))
+	expiration := metav1.NewTime(time.Now().Add(time.Minute))
+	ob.Status.Credentials = &v1alpha1.CredentialsStatus{IssueTime: &issued, ExpirationTime: &expiration}
+	if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(context.TODO(), ob, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating bucket status: %v", err)
+	}
+	if err = c.syncHandler(context.TODO(), key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	if p.provisioned != 2 || p.refreshed != 1 {
+		t.Errorf("Provision called %d times, Refresh called %d times, want 2 and 1", p.provisioned, p.refreshed)
+	}
+	if got := sessionToken(); got != "refreshed" {
+		t.Errorf("session token = %q, want %q", got, "refreshed")
+	}
+	if ob, err = objectBucketForKey(c, key); err != nil {
+		t.Fatalf("error getting bucket: %v", err)
+	}
+	if !ob.Status.Credentials.ExpirationTime.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expiration time not updated: %v", ob.Status.Credentials.ExpirationTime)
+	}
+	obc, err := claimForKey(context.TODO(), key, c.libClientset)
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	cond := meta.FindStatusCondition(obc.Status.Conditions, v1alpha1.ConditionCredentialsReady)
+	if cond == nil || cond.Reason != v1alpha1.ReasonRefreshed {
+		t.Errorf("CredentialsReady condition = %v, want reason %q", cond, v1alpha1.ReasonRefreshed)
+	}
+}
+
+type rotatingProvisioner struct {
+	fakeProvisioner
+	current string
+	rotated int
+	revoked []string
+}
+
+var _ api.CredentialRotator = &rotatingProvisioner{}
+
+func (p *rotatingProvisioner) Provision(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	ob, err := p.fakeProvisioner.Provision(options)
+	if err != nil {
+		return nil, err
+	}
+	ob.Spec.Authentication.AccessKeys.AccessKeyID = p.current
+	return ob, nil
+}
+
+func (p *rotatingProvisioner) RotateCredentials(ctx context.Context, ob *v1alpha1.ObjectBucket, options *api.BucketOptions) (*v1alpha1.Authentication, error) {
+	p.rotated++
+	p.current = fmt.Sprintf("key-%d", p.rotated)
+	return &v1alpha1.Authentication{AccessKeys: &v1alpha1.AccessKeys{AccessKeyID: p.current}}, nil
+}
+
+func (p *rotatingProvisioner) RevokeCredentials(ctx context.Context, ob *v1alpha1.ObjectBucket, options *api.BucketOptions, accessKeyID string) error {
+	p.revoked = append(p.revoked, accessKeyID)
+	return nil
+}
+
+func Test_obcController_syncHandler_rotateOnRequest(t *testing.T) {
+	key := testNamespace + "/" + testName
+	p := &rotatingProvisioner{current: "key-0"}
+	c, _ := newTestController(p, []runtime.Object{newTestStorageClass()}, []runtime.Object{newTestClaim()})
+
+	sync := func() {
+		t.Helper()
+		if err := c.syncHandler(context.TODO(), key); err != nil {
+			t.Fatalf("syncHandler() error = %v", err)
+		}
+	}
+	accessKeyID := func() string {
+		t.Helper()
+		secret, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("error getting secret: %v", err)
+		}
+		return secretValue(secret, v1alpha1.AwsKeyField)
+	}
+	getOB := func() *v1alpha1.ObjectBucket {
+		t.Helper()
+		ob, err := objectBucketForKey(c, key)
+		if err != nil {
+			t.Fatalf("error getting bucket: %v", err)
+		}
+		return ob
+	}
+
+	sync()
+	sync()
+	if p.rotated != 0 || accessKeyID() != "key-0" {
+		t.Fatalf("credentials rotated %d times without a request, access key %q", p.rotated, accessKeyID())
+	}
+
+	obc, err := claimForKey(context.TODO(), key, c.libClientset)
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	obc.Annotations = map[string]string{v1alpha1.RotateCredentialsAnnotation: "1"}
+	if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(testNamespace).Update(context.TODO(), obc, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating claim: %v", err)
+	}
+	sync()
+	if p.rotated != 1 || accessKeyID() != "key-1" {
+		t.Fatalf("credentials rotated %d times, access key %q, want 1 and %q", p.rotated, accessKeyID(), "key-1")
+	}
+	rotation := getOB().Status.Rotation
+	if rotation == nil || rotation.LastRotationTime == nil || rotation.LastRequest != "1" {
+		t.Fatalf("rotation status not recorded: %+v", rotation)
+	}
+	if rotation.PendingRevocation == nil || rotation.PendingRevocation.AccessKeyID != "key-0" {
+		t.Fatalf("previous access key not recorded for revocation: %+v", rotation.PendingRevocation)
+	}
+
+	// the previous key is kept during the overlap window, and the handled request is not repeated
+	sync()
+	if p.rotated != 1 || len(p.revoked) != 0 {
+		t.Fatalf("credentials rotated %d times, revoked %v during the overlap window", p.rotated, p.revoked)
+	}
+
+	ob := getOB()
+	ob.Status.Rotation.PendingRevocation.RevokeTime = metav1.NewTime(time.Now().Add(-time.Second))
+	if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(context.TODO(), ob, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating bucket status: %v", err)
+	}
+	sync()
+	sync()
+	if !cmp.Equal([]string{"key-0"}, p.revoked) {
+		t.Errorf("revoked access keys: %s", cmp.Diff([]string{"key-0"}, p.revoked))
+	}
+	if rotation = getOB().Status.Rotation; rotation.PendingRevocation != nil || rotation.LastRotationTime == nil {
+		t.Errorf("rotation status after revocation: %+v", rotation)
+	}
+	if p.rotated != 1 || accessKeyID() != "key-1" {
+		t.Errorf("credentials rotated %d times, access key %q, want 1 and %q", p.rotated, accessKeyID(), "key-1")
+	}
+}
+
+func Test_obcController_syncHandler_rotateOnInterval(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name         string
+		interval     string
+		lastRotation time.Duration
+		wantRotated  int
+		wantPhase    v1alpha1.ObjectBucketClaimStatusPhase
+	}{
+		{
+			name:         "not due",
+			interval:     "1h",
+			lastRotation: 10 * time.Minute,
+			wantPhase:    v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:         "due",
+			interval:     "1h",
+			lastRotation: 2 * time.Hour,
+			wantRotated:  1,
+			wantPhase:    v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:      "invalid interval",
+			interval:  "monthly",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := newTestStorageClass()
+			class.Parameters = map[string]string{v1alpha1.StorageClassRotationInterval: tt.interval}
+			obc := newTestClaim()
+			obc.Spec.BucketName = "test-bucket"
+			obc.Spec.ObjectBucketName = "obc-" + testNamespace + "-" + testName
+			obc.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+			lastRotation := metav1.NewTime(time.Now().Add(-tt.lastRotation))
+			ob := &v1alpha1.ObjectBucket{
+				ObjectMeta: metav1.ObjectMeta{Name: obc.Spec.ObjectBucketName},
+				Spec: v1alpha1.ObjectBucketSpec{
+					StorageClassName: className,
+					ClaimRef:         makeObjectReference(obc),
+					Connection: &v1alpha1.Connection{
+						Endpoint: &v1alpha1.Endpoint{BucketName: obc.Spec.BucketName},
+					},
+				},
+				Status: v1alpha1.ObjectBucketStatus{
+					Phase:    v1alpha1.ObjectBucketStatusPhaseBound,
+					Rotation: &v1alpha1.CredentialRotationStatus{LastRotationTime: &lastRotation},
+				},
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            testName,
+					Namespace:       testNamespace,
+					OwnerReferences: []metav1.OwnerReference{makeOwnerReference(obc)},
+				},
+				StringData: map[string]string{v1alpha1.AwsKeyField: "key-0"},
+			}
+			p := &rotatingProvisioner{current: "key-0"}
+			c, _ := newTestController(p, []runtime.Object{class, secret}, []runtime.Object{obc, ob})
+
+			_ = c.syncHandler(context.TODO(), key)
+
+			if p.rotated != tt.wantRotated {
+				t.Errorf("credentials rotated %d times, want %d", p.rotated, tt.wantRotated)
+			}
+			gotOBC, err := claimForKey(context.TODO(), key, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if gotOBC.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", gotOBC.Status.Phase, tt.wantPhase)
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_generatedObjectNames(t *testing.T) {
+	key := testNamespace + "/" + testName
+	unowned := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace}}
+	unownedBinding := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: testName + "-credentials-binding", Namespace: testNamespace}}
+
+	tests := []struct {
+		name          string
+		params        map[string]string
+		wantPhase     v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason    string
+		wantSecret    string
+		wantConfigMap string
+	}{
+		{
+			name:       "existing secret not owned by the claim",
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason: liberrors.ReasonNameConflict,
+		},
+		{
+			name: "existing binding secret not owned by the claim",
+			params: map[string]string{
+				v1alpha1.StorageClassSecretName:     "{{ .Name }}-credentials",
+				v1alpha1.StorageClassServiceBinding: "true",
+				v1alpha1.StorageClassConfigMapName:  "{{ .Name }}-endpoint",
+			},
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason: liberrors.ReasonNameConflict,
+		},
+		{
+			name: "invalid key mapping",
+			params: map[string]string{
+				v1alpha1.StorageClassSecretName:       "{{ .Name }}-credentials",
+				v1alpha1.StorageClassConfigMapName:    "{{ .Name }}-endpoint",
+				v1alpha1.StorageClassSecretKeyMapping: "AWS_ACCESS_KEY_ID",
+			},
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason: liberrors.ReasonInvalidParameters,
+		},
+		{
+			name: "unsupported connection format",
+			params: map[string]string{
+				v1alpha1.StorageClassSecretName:        "{{ .Name }}-credentials",
+				v1alpha1.StorageClassConfigMapName:     "{{ .Name }}-endpoint",
+				v1alpha1.StorageClassConnectionFormats: "ini",
+			},
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason: liberrors.ReasonInvalidParameters,
+		},
+		{
+			name: "names generated by the storage class",
+			params: map[string]string{
+				v1alpha1.StorageClassSecretName:    "{{ .Name }}-credentials",
+				v1alpha1.StorageClassConfigMapName: "{{ .Name }}-endpoint",
+			},
+			wantPhase:     v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantSecret:    testName + "-credentials",
+			wantConfigMap: testName + "-endpoint",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := newTestStorageClass()
+			class.Parameters = tt.params
+			c, _ := newTestController(&fakeProvisioner{}, []runtime.Object{class, unowned, unownedBinding}, []runtime.Object{newTestClaim()})
+
+			_ = c.syncHandler(context.TODO(), key)
+
+			obc, err := claimForKey(context.TODO(), key, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if obc.Status.Phase != tt.wantPhase {
+				t.Fatalf("phase = %q, want %q", obc.Status.Phase, tt.wantPhase)
+			}
+			if tt.wantPhase == v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+				cond := meta.FindStatusCondition(obc.Status.Conditions, v1alpha1.ConditionProvisioned)
+				if cond == nil || cond.Reason != tt.wantReason {
+					t.Errorf("Provisioned condition = %v, want reason %q", cond, tt.wantReason)
+				}
+				for _, name := range []string{unowned.Name, unownedBinding.Name} {
+					secret, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+					if err != nil || len(secret.OwnerReferences) != 0 || len(secret.StringData) != 0 {
+						t.Errorf("unowned secret was modified: %v, %v", secret, err)
+					}
+				}
+				// the claim fails before the bucket is provisioned
+				if ob, err := c.objectBucketForClaim(context.TODO(), obc); err != nil || ob != nil {
+					t.Errorf("ObjectBucket = %v, %v, want none", ob, err)
+				}
+				return
+			}
+			if _, err = c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), tt.wantSecret, metav1.GetOptions{}); err != nil {
+				t.Errorf("error getting secret %q: %v", tt.wantSecret, err)
+			}
+			if _, err = c.clientset.CoreV1().ConfigMaps(testNamespace).Get(context.TODO(), tt.wantConfigMap, metav1.GetOptions{}); err != nil {
+				t.Errorf("error getting configmap %q: %v", tt.wantConfigMap, err)
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_invalidBucketName(t *testing.T) {
+	key := testNamespace + "/" + testName
+	obc := newTestClaim()
+	obc.Spec.GenerateBucketName = ""
+	obc.Spec.BucketName = "Invalid_Bucket"
+	c, _ := newTestController(&fakeProvisioner{}, []runtime.Object{newTestStorageClass()}, []runtime.Object{obc})
+
+	if err := c.syncHandler(context.TODO(), key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+
+	got, err := claimForKey(context.TODO(), key, c.libClientset)
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if got.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+		t.Errorf("phase = %q, want %q", got.Status.Phase, v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+	}
+	cond := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.ConditionProvisioned)
+	if cond == nil || cond.Reason != liberrors.ReasonInvalidBucketName {
+		t.Errorf("Provisioned condition = %v, want reason %q", cond, liberrors.ReasonInvalidBucketName)
+	}
+}
+
+// takenProvisioner reports the first taken bucket names as existing in the object store.
+type takenProvisioner struct {
+	fakeProvisioner
+	taken int
+	names []string
+}
+
+func (p *takenProvisioner) Provision(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	p.names = append(p.names, options.BucketName)
+	if len(p.names) <= p.taken {
+		return nil, liberrors.NewBucketExistsError("bucket " + options.BucketName + " exists")
+	}
+	return p.fakeProvisioner.Provision(options)
+}
+
+func Test_obcController_syncHandler_bucketExists(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name       string
+		bucketName string
+		// keepPrefix keeps the claim's generateBucketName alongside bucketName
+		keepPrefix bool
+		taken      int
+		wantCalls  int
+		wantPhase  v1alpha1.ObjectBucketClaimStatusPhase
+	}{
+		{
+			name:      "generated name taken",
+			taken:     2,
+			wantCalls: 3,
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:      "generated names always taken",
+			taken:     maxBucketNameRegenerations + 1,
+			wantCalls: maxBucketNameRegenerations + 1,
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+		{
+			name:       "explicit name taken",
+			bucketName: "taken-bucket",
+			taken:      1,
+			wantCalls:  1,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+		{
+			name:       "explicit name with generateBucketName taken",
+			bucketName: "taken-bucket",
+			keepPrefix: true,
+			taken:      1,
+			wantCalls:  1,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+		{
+			name:       "generated name stored earlier taken",
+			bucketName: generateBucketName(newTestClaim().Spec.GenerateBucketName, api.S3BucketNameRules{}),
+			keepPrefix: true,
+			taken:      1,
+			wantCalls:  2,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim()
+			if tt.bucketName != "" {
+				if !tt.keepPrefix {
+					obc.Spec.GenerateBucketName = ""
+				}
+				obc.Spec.BucketName = tt.bucketName
+			}
+			p := &takenProvisioner{taken: tt.taken}
+			c, _ := newTestController(p, []runtime.Object{newTestStorageClass()}, []runtime.Object{obc})
+
+			if err := c.syncHandler(context.TODO(), key); err != nil {
+				t.Fatalf("syncHandler() error = %v", err)
+			}
+
+			if len(p.names) != tt.wantCalls {
+				t.Fatalf("Provision called with %v, want %d calls", p.names, tt.wantCalls)
+			}
+			seen := map[string]bool{}
+			for _, name := range p.names {
+				if seen[name] && tt.bucketName == "" {
+					t.Errorf("generated bucket name %q provisioned twice", name)
+				}
+				seen[name] = true
+			}
+			got, err := claimForKey(context.TODO(), key, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.Phase != tt.wantPhase {
+				t.Errorf("phase = %q, want %q", got.Status.Phase, tt.wantPhase)
+			}
+			if last := p.names[len(p.names)-1]; got.Spec.BucketName != last {
+				t.Errorf("claim bucketName = %q, want the last provisioned name %q", got.Spec.BucketName, last)
+			}
+			if tt.wantPhase == v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+				cond := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.ConditionProvisioned)
+				if cond == nil || cond.Reason != liberrors.ReasonBucketExists {
+					t.Errorf("Provisioned condition = %v, want reason %q", cond, liberrors.ReasonBucketExists)
+				}
+			}
+		})
+	}
+}
+
+// deleteTestClaim marks the claim of key as deleted and syncs it.
+func deleteTestClaim(t *testing.T, c *obcController, key string) {
+	t.Helper()
+	obc, err := claimForKey(context.TODO(), key, c.libClientset)
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	now := metav1.Now()
+	obc.DeletionTimestamp = &now
+	if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(context.TODO(), obc, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating claim: %v", err)
+	}
+	if err = c.syncHandler(context.TODO(), key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+}
+
+// invalidConnectionProvisioner returns a connection which cannot be written to the claim's Secret
+// or ConfigMap, and records the calls made on deletion.
+type invalidConnectionProvisioner struct {
+	reclaimingProvisioner
+	invalidate func(ob *v1alpha1.ObjectBucket)
+}
+
+func (p *invalidConnectionProvisioner) Provision(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+	ob, err := p.fakeProvisioner.Provision(options)
+	if err == nil {
+		p.invalidate(ob)
+	}
+	return ob, err
+}
+
+func Test_obcController_syncHandler_invalidConnectionIsCleanedUp(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name       string
+		invalidate func(ob *v1alpha1.ObjectBucket)
+	}{
+		{
+			name: "reserved secret key",
+			invalidate: func(ob *v1alpha1.ObjectBucket) {
+				ob.Spec.Authentication.AdditionalSecretData = map[string]string{v1alpha1.AwsKeyField: "reserved"}
+			},
+		},
+		{
+			name: "unsupported addressing style",
+			invalidate: func(ob *v1alpha1.ObjectBucket) {
+				ob.Spec.Endpoint.AddressingStyle = "bogus"
+			},
+		},
+		{
+			name: "invalid url",
+			invalidate: func(ob *v1alpha1.ObjectBucket) {
+				ob.Spec.Endpoint.URL = "s3.example.com"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &invalidConnectionProvisioner{invalidate: tt.invalidate}
+			c, _ := newTestController(p, []runtime.Object{newTestStorageClass()}, []runtime.Object{newTestClaim()})
+
+			if err := c.syncHandler(context.TODO(), key); err != nil {
+				t.Fatalf("syncHandler() error = %v", err)
+			}
+			obc, err := claimForKey(context.TODO(), key, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+				t.Errorf("claim phase = %q, want %q", obc.Status.Phase, v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+			}
+			// the OB is written before the Secret and ConfigMap, so that the provisioned bucket can be found
+			if ob, err := c.objectBucketForClaim(context.TODO(), obc); err != nil || ob == nil {
+				t.Fatalf("no ObjectBucket for the failed claim: %v", err)
+			}
+
+			deleteTestClaim(t, c, key)
+			if strings.Join(p.calls, ",") != operationDelete {
+				t.Errorf("provisioner calls = %v, want [%s]", p.calls, operationDelete)
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_serviceBinding(t *testing.T) {
+	key := testNamespace + "/" + testName
+	bindingName := testName + "-binding"
+
+	tests := []struct {
+		name        string
+		params      map[string]string
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		wantBinding bool
+	}{
+		{
+			name:      "disabled by default",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:        "enabled",
+			params:      map[string]string{v1alpha1.StorageClassServiceBinding: "true"},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantBinding: true,
+		},
+		{
+			name:      "invalid parameter",
+			params:    map[string]string{v1alpha1.StorageClassServiceBinding: "yes please"},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := newTestStorageClass()
+			class.Parameters = tt.params
+			c, _ := newTestController(&fakeProvisioner{}, []runtime.Object{class}, []runtime.Object{newTestClaim()})
+
+			_ = c.syncHandler(context.TODO(), key)
+
+			obc, err := claimForKey(context.TODO(), key, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if obc.Status.Phase != tt.wantPhase {
+				t.Fatalf("phase = %q, want %q", obc.Status.Phase, tt.wantPhase)
+			}
+			secret, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), bindingName, metav1.GetOptions{})
+			if !tt.wantBinding {
+				if obc.Status.Binding != nil || err == nil {
+					t.Errorf("unexpected binding %v, secret %v", obc.Status.Binding, secret)
+				}
+				return
+			}
+			if obc.Status.Binding == nil || obc.Status.Binding.Name != bindingName {
+				t.Errorf("status.binding = %v, want name %q", obc.Status.Binding, bindingName)
+			}
+			if err != nil {
+				t.Fatalf("error getting binding secret: %v", err)
+			}
+			if secret.Type != "servicebinding.io/s3" || secretValue(secret, "type") != "s3" || secretValue(secret, "provider") != class.Provisioner {
+				t.Errorf("unexpected binding secret %v", secret)
+			}
+			if !metav1.IsControlledBy(secret, obc) {
+				t.Errorf("binding secret is not owned by the claim")
+			}
+		})
+	}
+}
+
+func Test_obcController_enqueueGeneratedResources(t *testing.T) {
+	key := testNamespace + "/" + testName
+	obc := newTestClaim()
+	owned := metav1.ObjectMeta{
+		Name:            testName,
+		Namespace:       testNamespace,
+		OwnerReferences: []metav1.OwnerReference{makeOwnerReference(obc)},
+	}
+
+	tests := []struct {
+		name    string
+		enqueue func(c *obcController)
+		want    []string
+	}{
+		{
+			name: "owned secret",
+			enqueue: func(c *obcController) {
+				c.enqueueOwner(&corev1.Secret{ObjectMeta: owned})
+			},
+			want: []string{key},
+		},
+		{
+			name: "deleted configmap",
+			enqueue: func(c *obcController) {
+				c.enqueueOwner(cache.DeletedFinalStateUnknown{Key: key, Obj: &corev1.ConfigMap{ObjectMeta: owned}})
+			},
+			want: []string{key},
+		},
+		{
+			name: "unowned secret",
+			enqueue: func(c *obcController) {
+				c.enqueueOwner(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace}})
+			},
+		},
+		{
+			name: "bound bucket",
+			enqueue: func(c *obcController) {
+				c.enqueueClaimOfBucket(&v1alpha1.ObjectBucket{Spec: v1alpha1.ObjectBucketSpec{ClaimRef: makeObjectReference(obc)}})
+			},
+			want: []string{key},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestController(&fakeProvisioner{}, nil, nil)
+			tt.enqueue(c)
+			var got []string
+			for c.queue.Len() > 0 {
+				item, _ := c.queue.Get()
+				got = append(got, item.(string))
+				c.queue.Done(item)
+			}
+			if !cmp.Equal(tt.want, got) {
+				t.Errorf("queued keys: %s", cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_restoresDrift(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, c *obcController)
+	}{
+		{
+			name: "edited secret",
+			tamper: func(t *testing.T, c *obcController) {
+				secret, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("error getting secret: %v", err)
+				}
+				secret.StringData = map[string]string{v1alpha1.AwsKeyField: "tampered"}
+				if _, err = c.clientset.CoreV1().Secrets(testNamespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("error updating secret: %v", err)
+				}
+			},
+		},
+		{
+			name: "deleted configmap",
+			tamper: func(t *testing.T, c *obcController) {
+				if err := c.clientset.CoreV1().ConfigMaps(testNamespace).Delete(context.TODO(), testName, metav1.DeleteOptions{}); err != nil {
+					t.Fatalf("error deleting configmap: %v", err)
+				}
+			},
+		},
+		{
+			name: "deleted bucket",
+			tamper: func(t *testing.T, c *obcController) {
+				if err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Delete(context.TODO(), objectBucketNameForClaim(newTestClaim()), metav1.DeleteOptions{}); err != nil {
+					t.Fatalf("error deleting bucket: %v", err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestController(&fakeProvisioner{}, []runtime.Object{newTestStorageClass()}, []runtime.Object{newTestClaim()})
+			if err := c.syncHandler(context.TODO(), key); err != nil {
+				t.Fatalf("syncHandler() error = %v", err)
+			}
+			tt.tamper(t, c)
+			if err := c.syncHandler(context.TODO(), key); err != nil {
+				t.Fatalf("syncHandler() error = %v", err)
+			}
+
+			secret, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting secret: %v", err)
+			}
+			if _, ok := secret.StringData[v1alpha1.AwsKeyField]; !ok || secret.StringData[v1alpha1.AwsKeyField] == "tampered" {
+				t.Errorf("secret was not restored: %v", secret.StringData)
+			}
+			if _, err = c.clientset.CoreV1().ConfigMaps(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{}); err != nil {
+				t.Errorf("configmap was not restored: %v", err)
+			}
+			if _, err = objectBucketForKey(c, key); err != nil {
+				t.Errorf("bucket was not restored: %v", err)
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_collidingClaimKeys(t *testing.T) {
+	first := newTestClaim()
+	first.Namespace, first.Name, first.UID = "a-b", "c", "0b6b4d4e-1111-4c8e-9a57-5a3b0d1c2e01"
+	second := newTestClaim()
+	second.Namespace, second.Name, second.UID = "a", "b-c", "0b6b4d4e-2222-4c8e-9a57-5a3b0d1c2e02"
+	c, _ := newTestController(&fakeProvisioner{}, []runtime.Object{newTestStorageClass()}, []runtime.Object{first, second})
+
+	names := map[string]bool{}
+	for _, obc := range []*v1alpha1.ObjectBucketClaim{first, second} {
+		key := obc.Namespace + "/" + obc.Name
+		if err := c.syncHandler(context.TODO(), key); err != nil {
+			t.Fatalf("syncHandler(%q) error = %v", key, err)
+		}
+		ob, err := objectBucketForKey(c, key)
+		if err != nil {
+			t.Fatalf("error getting ob of %q: %v", key, err)
+		}
+		if !claimRefMatches(ob.Spec.ClaimRef, obc) {
+			t.Errorf("ob %q of %q refers to claim %v", ob.Name, key, ob.Spec.ClaimRef)
+		}
+		names[ob.Name] = true
+	}
+	if len(names) != 2 {
+		t.Errorf("claims share ObjectBucket %v", names)
+	}
+}
+
+func Test_obcController_objectBucketForClaim(t *testing.T) {
+	legacyName := "obc-" + testNamespace + "-" + testName
+	legacyRef := makeObjectReference(newTestClaim())
+	legacyRef.UID = ""
+	otherRef := makeObjectReference(newTestClaim())
+	otherRef.UID = "a-deleted-claim"
+
+	newOB := func(name string, ref *corev1.ObjectReference) *v1alpha1.ObjectBucket {
+		return &v1alpha1.ObjectBucket{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.ObjectBucketSpec{ClaimRef: ref},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		obName     string
+		ob         *v1alpha1.ObjectBucket
+		indexed    bool
+		wantOBName string
+	}{
+		{
+			name: "no ob",
+		},
+		{
+			name:       "unbound claim",
+			ob:         newOB(objectBucketNameForClaim(newTestClaim()), makeObjectReference(newTestClaim())),
+			wantOBName: objectBucketNameForClaim(newTestClaim()),
+		},
+		{
+			name:       "legacy ob named in claim",
+			obName:     legacyName,
+			ob:         newOB(legacyName, legacyRef),
+			wantOBName: legacyName,
+		},
+		{
+			name:       "legacy ob not named in claim",
+			ob:         newOB(legacyName, legacyRef),
+			indexed:    true,
+			wantOBName: legacyName,
+		},
+		{
+			name:    "ob of a deleted claim of the same name",
+			ob:      newOB(legacyName, otherRef),
+			indexed: true,
+		},
+		{
+			name:   "ob named in claim bound to another claim",
+			obName: legacyName,
+			ob:     newOB(legacyName, otherRef),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim()
+			obc.Spec.ObjectBucketName = tt.obName
+			var libObjs []runtime.Object
+			if tt.ob != nil {
+				libObjs = append(libObjs, tt.ob)
+			}
+			c, _ := newTestController(&fakeProvisioner{}, nil, libObjs)
+			if tt.indexed {
+				if err := c.obIndexer.Add(tt.ob); err != nil {
+					t.Fatalf("error indexing ob: %v", err)
+				}
+			}
+
+			got, err := c.objectBucketForClaim(context.TODO(), obc)
+			if err != nil {
+				t.Fatalf("objectBucketForClaim() error = %v", err)
+			}
+			var gotName string
+			if got != nil {
+				gotName = got.Name
+			}
+			if gotName != tt.wantOBName {
+				t.Errorf("objectBucketForClaim() = %q, want %q", gotName, tt.wantOBName)
+			}
+		})
+	}
+}
+
+func Test_obcController_migrateObjectBuckets(t *testing.T) {
+	legacyName := "obc-" + testNamespace + "-" + testName
+	obLabels := map[string]string{provisionerLabelKey: labelValue(provisionerName)}
+
+	tests := []struct {
+		name       string
+		obName     string
+		refUID     types.UID
+		wantOBName string
+		wantUID    types.UID
+	}{
+		{
+			name:       "legacy ob",
+			wantOBName: legacyName,
+			wantUID:    objMeta.UID,
+		},
+		{
+			name:       "legacy ob named in claim",
+			obName:     legacyName,
+			wantOBName: legacyName,
+			wantUID:    objMeta.UID,
+		},
+		{
+			name:       "claim bound to another ob",
+			obName:     "other-ob",
+			wantOBName: "other-ob",
+		},
+		{
+			name:    "ob of a deleted claim of the same name",
+			refUID:  "a-deleted-claim",
+			wantUID: "a-deleted-claim",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim()
+			obc.Spec.ObjectBucketName = tt.obName
+			obc.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+			ref := makeObjectReference(newTestClaim())
+			ref.UID = tt.refUID
+			ob := &v1alpha1.ObjectBucket{
+				ObjectMeta: metav1.ObjectMeta{Name: legacyName, Labels: obLabels},
+				Spec:       v1alpha1.ObjectBucketSpec{ClaimRef: ref},
+			}
+			c, _ := newTestController(&fakeProvisioner{}, nil, []runtime.Object{obc, ob})
+			if err := c.obIndexer.Add(ob); err != nil {
+				t.Fatalf("error indexing ob: %v", err)
+			}
+
+			// the migration is repeated to check that it is idempotent
+			for i := 0; i < 2; i++ {
+				c.migrateObjectBuckets(context.TODO())
+			}
+
+			gotOBC, err := claimForKey(context.TODO(), testNamespace+"/"+testName, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if gotOBC.Spec.ObjectBucketName != tt.wantOBName {
+				t.Errorf("claim objectBucketName = %q, want %q", gotOBC.Spec.ObjectBucketName, tt.wantOBName)
+			}
+			if gotOBC.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+				t.Errorf("claim phase = %q, want %q", gotOBC.Status.Phase, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			}
+			gotOB, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), legacyName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting ob: %v", err)
+			}
+			if gotOB.Spec.ClaimRef.UID != tt.wantUID {
+				t.Errorf("ob claimRef UID = %q, want %q", gotOB.Spec.ClaimRef.UID, tt.wantUID)
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_multipleProvisioners(t *testing.T) {
+	east, west := &takenProvisioner{}, &takenProvisioner{}
+	provisioners := map[string]api.ProvisionerV2{
+		"ceph.rook.io/bucket-east": api.AdaptProvisioner(east),
+		"ceph.rook.io/bucket-west": api.AdaptProvisioner(west),
+	}
+	var kubeObjs, libObjs []runtime.Object
+	for _, name := range []string{"east", "west", "other"} {
+		class := newTestStorageClass()
+		class.Name = name
+		class.Provisioner = "ceph.rook.io/bucket-" + name
+		obc := newTestClaim()
+		obc.Name, obc.UID = name, types.UID(name+"-uid")
+		obc.Spec.StorageClassName = name
+		obc.Spec.GenerateBucketName = name
+		kubeObjs = append(kubeObjs, class)
+		libObjs = append(libObjs, obc)
+	}
+	client := fake.NewSimpleClientset(kubeObjs...)
+	libClient := externalFake.NewSimpleClientset(libObjs...)
+	factory := informers.NewSharedInformerFactory(libClient, 0)
+	c := newController(provisioners, client, libClient,
+		factory.Objectbucket().V1alpha1().ObjectBucketClaims(),
+		factory.Objectbucket().V1alpha1().ObjectBuckets(),
+		newOptions(WithEventRecorder(record.NewFakeRecorder(100))))
+
+	for _, name := range []string{"east", "west", "other"} {
+		if err := c.syncHandler(context.TODO(), testNamespace+"/"+name); err != nil {
+			t.Fatalf("syncHandler(%q) error = %v", name, err)
+		}
+	}
+
+	for name, p := range map[string]*takenProvisioner{"east": east, "west": west} {
+		if len(p.names) != 1 || !strings.HasPrefix(p.names[0], name+"-") {
+			t.Errorf("%s provisioner provisioned %v, want a single %q bucket", name, p.names, name)
+		}
+		ob, err := objectBucketForKey(c, testNamespace+"/"+name)
+		if err != nil {
+			t.Fatalf("error getting ob of %q: %v", name, err)
+		}
+		if got, want := ob.Labels[provisionerLabelKey], "ceph.rook.io-bucket-"+name; got != want {
+			t.Errorf("ob of %q labeled %q, want %q", name, got, want)
+		}
+		if !c.isProvisionerObject(ob) {
+			t.Errorf("ob of %q is not recognized as the controller's", name)
+		}
+	}
+	other, err := claimForKey(context.TODO(), testNamespace+"/other", libClient)
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if other.Spec.BucketName != "" || len(other.Finalizers) != 0 {
+		t.Errorf("claim of another provisioner was provisioned: %+v", other.Spec)
+	}
+}
+
+// reclaimingProvisioner implements the optional interfaces of the library defined reclaim
+// policies and records the calls made on deletion.
+type reclaimingProvisioner struct {
+	fakeProvisioner
+	calls []string
+}
+
+func (p *reclaimingProvisioner) Delete(ob *v1alpha1.ObjectBucket) error {
+	p.calls = append(p.calls, operationDelete)
+	return nil
+}
+
+func (p *reclaimingProvisioner) Revoke(ob *v1alpha1.ObjectBucket) error {
+	p.calls = append(p.calls, operationRevoke)
+	return nil
+}
+
+func (p *reclaimingProvisioner) Erase(ctx context.Context, ob *v1alpha1.ObjectBucket) error {
+	p.calls = append(p.calls, operationErase)
+	return nil
+}
+
+func (p *reclaimingProvisioner) Suspend(ctx context.Context, ob *v1alpha1.ObjectBucket) error {
+	p.calls = append(p.calls, operationSuspend)
+	return nil
+}
+
+func (p *reclaimingProvisioner) Archive(ctx context.Context, ob *v1alpha1.ObjectBucket) error {
+	p.calls = append(p.calls, operationArchive)
+	return nil
+}
+
+func Test_obcController_syncHandler_reclaimPolicies(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name string
+		// param sets the reclaimPolicy parameter of the storage class
+		param string
+		// obPolicy overwrites the reclaim policy of the OB after provisioning
+		obPolicy  corev1.PersistentVolumeReclaimPolicy
+		wantCalls []string
+	}{
+		{
+			name:      "delete",
+			wantCalls: []string{operationDelete},
+		},
+		{
+			name:      "retain",
+			param:     "Retain",
+			wantCalls: []string{operationRevoke},
+		},
+		{
+			name:      "erase",
+			param:     "Erase",
+			wantCalls: []string{operationErase, operationRevoke},
+		},
+		{
+			name:      "suspend",
+			param:     "Suspend",
+			wantCalls: []string{operationSuspend},
+		},
+		{
+			name:      "archive",
+			param:     "Archive",
+			wantCalls: []string{operationArchive, operationRevoke},
+		},
+		{
+			name:      "set on the OB",
+			obPolicy:  v1alpha1.ReclaimPolicyArchive,
+			wantCalls: []string{operationArchive, operationRevoke},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := newTestStorageClass()
+			if tt.param != "" {
+				class.Parameters = map[string]string{v1alpha1.StorageClassReclaimPolicy: tt.param}
+			}
+			p := &reclaimingProvisioner{}
+			c, _ := newTestController(p, []runtime.Object{class}, []runtime.Object{newTestClaim()})
+			if err := c.syncHandler(context.TODO(), key); err != nil {
+				t.Fatalf("syncHandler() error = %v", err)
+			}
+			ob, err := objectBucketForKey(c, key)
+			if err != nil {
+				t.Fatalf("error getting bucket: %v", err)
+			}
+			if tt.param != "" && string(*ob.Spec.ReclaimPolicy) != tt.param {
+				t.Errorf("OB reclaimPolicy = %q, want %q", *ob.Spec.ReclaimPolicy, tt.param)
+			}
+			if tt.obPolicy != "" {
+				ob.Spec.ReclaimPolicy = &tt.obPolicy
+				if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("error updating bucket: %v", err)
+				}
+			}
+
+			obc, err := claimForKey(context.TODO(), key, c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			obc.DeletionTimestamp = &now
+			if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(testNamespace).Update(context.TODO(), obc, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("error updating claim: %v", err)
+			}
+			if err = c.syncHandler(context.TODO(), key); err != nil {
+				t.Fatalf("syncHandler() error = %v", err)
+			}
+			if strings.Join(p.calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("provisioner calls = %v, want %v", p.calls, tt.wantCalls)
+			}
+			if obc, err = claimForKey(context.TODO(), key, c.libClientset); err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if len(obc.Finalizers) != 0 {
+				t.Errorf("claim was not released, finalizers = %v", obc.Finalizers)
+			}
+		})
+	}
+}
+
+func Test_obcController_syncHandler_suspendKeepsCredentials(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	class := newTestStorageClass()
+	class.Parameters = map[string]string{v1alpha1.StorageClassReclaimPolicy: string(v1alpha1.ReclaimPolicySuspend)}
+	c, _ := newTestController(&reclaimingProvisioner{}, []runtime.Object{class}, []runtime.Object{newTestClaim()})
+	if err := c.syncHandler(context.TODO(), key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	ob, err := objectBucketForKey(c, key)
+	if err != nil {
+		t.Fatalf("error getting bucket: %v", err)
+	}
+	secret, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting secret: %v", err)
+	}
+
+	deleteTestClaim(t, c, key)
+
+	// the OB and the Secret outlive the claim, and the Secret is not garbage collected with it
+	if ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), ob.Name, metav1.GetOptions{}); err != nil {
+		t.Fatalf("ObjectBucket of the suspended bucket was deleted: %v", err)
+	}
+	if ob.Status.Phase != v1alpha1.ObjectBucketStatusPhaseReleased || len(ob.Finalizers) != 0 {
+		t.Errorf("OB phase = %q and finalizers = %v, want %q and none", ob.Status.Phase, ob.Finalizers, v1alpha1.ObjectBucketStatusPhaseReleased)
+	}
+	kept, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Secret of the suspended bucket was deleted: %v", err)
+	}
+	if len(kept.OwnerReferences) != 0 || len(kept.Finalizers) != 0 {
+		t.Errorf("Secret owner references = %v and finalizers = %v, want none", kept.OwnerReferences, kept.Finalizers)
+	}
+	if diff := cmp.Diff(secret.StringData, kept.StringData); diff != "" {
+		t.Errorf("Secret credentials changed (-want +got):\n%s", diff)
+	}
+	if obc, err := claimForKey(context.TODO(), key, c.libClientset); err != nil || len(obc.Finalizers) != 0 {
+		t.Errorf("claim was not released: %v, %v", obc, err)
+	}
+}
+
+func Test_obcController_syncHandler_unsupportedReclaimPolicy(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	// a storage class policy which the provisioner does not implement fails the claim
+	class := newTestStorageClass()
+	class.Parameters = map[string]string{v1alpha1.StorageClassReclaimPolicy: "Erase"}
+	c, _ := newTestController(&fakeProvisioner{}, []runtime.Object{class}, []runtime.Object{newTestClaim()})
+	if err := c.syncHandler(context.TODO(), key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	obc, err := claimForKey(context.TODO(), key, c.libClientset)
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+		t.Errorf("claim phase = %q, want %q", obc.Status.Phase, v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+	}
+
+	// so does an OB policy on deletion, which leaves the OB Failed
+	c, _ = newTestController(&fakeProvisioner{}, []runtime.Object{newTestStorageClass()}, []runtime.Object{newTestClaim()})
+	if err = c.syncHandler(context.TODO(), key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	ob, err := objectBucketForKey(c, key)
+	if err != nil {
+		t.Fatalf("error getting bucket: %v", err)
+	}
+	policy := v1alpha1.ReclaimPolicySuspend
+	ob.Spec.ReclaimPolicy = &policy
+	if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating bucket: %v", err)
+	}
+	if obc, err = claimForKey(context.TODO(), key, c.libClientset); err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	now := metav1.Now()
+	obc.DeletionTimestamp = &now
+	if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(testNamespace).Update(context.TODO(), obc, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating claim: %v", err)
+	}
+	if err = c.syncHandler(context.TODO(), key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	if ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), ob.Name, metav1.GetOptions{}); err != nil {
+		t.Fatalf("error getting bucket: %v", err)
+	}
+	if ob.Status.Phase != v1alpha1.ObjectBucketStatusPhaseFailed {
+		t.Errorf("OB phase = %q, want %q", ob.Status.Phase, v1alpha1.ObjectBucketStatusPhaseFailed)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned/scheme"
+)
+
+// Event reasons recorded against OBCs and OBs. These are visible in `kubectl describe` and should
+// be treated as part of the library's API since users may filter on them.
+const (
+	reasonStorageClassLookupFailed = "StorageClassLookupFailed"
+	reasonPending                  = "Pending"
+	reasonProvisioning             = "Provisioning"
+	reasonProvisioningFailed       = "ProvisioningFailed"
+	reasonBound                    = "Bound"
+	reasonBucketNameRegenerated    = "BucketNameRegenerated"
+	reasonUpdated                  = "BucketUpdated"
+	reasonUpdateFailed             = "BucketUpdateFailed"
+	reasonRefreshed                = "CredentialsRefreshed"
+	reasonRefreshFailed            = "CredentialsRefreshFailed"
+	reasonRotated                  = "CredentialsRotated"
+	reasonRotationFailed           = "CredentialsRotationFailed"
+	reasonCredentialsRevoked       = "PreviousCredentialsRevoked"
+	reasonCredentialsRevokeFailed  = "PreviousCredentialsRevokeFailed"
+	reasonReleased                 = "Released"
+	reasonDeleted                  = "BucketDeleted"
+	reasonDeleteFailed             = "BucketDeleteFailed"
+	reasonRevoked                  = "AccessRevoked"
+	reasonRevokeFailed             = "AccessRevokeFailed"
+	reasonReclaimed                = "BucketReclaimed"
+	reasonReclaimFailed            = "BucketReclaimFailed"
+	reasonReleaseFailed            = "FinalizerReleaseFailed"
+	reasonFailed                   = "Failed"
+)
+
+// newEventBroadcaster returns a broadcaster which writes events to the API server and logs them.
+func newEventBroadcaster(c kubernetes.Interface, logger logr.Logger) record.EventBroadcaster {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(func(format string, args ...interface{}) {
+		logger.V(1).Info("event recorded", "event", fmt.Sprintf(format, args...))
+	})
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: c.CoreV1().Events("")})
+	return broadcaster
+}
+
+// newEventRecorder returns a recorder which attributes events to the named provisioner. The
+// recorder's scheme must be aware of the OBC and OB types in order to build object references.
+func newEventRecorder(broadcaster record.EventBroadcaster, provisionerName string) record.EventRecorder {
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: provisionerName})
+}
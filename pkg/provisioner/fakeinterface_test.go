@@ -24,7 +24,11 @@ import (
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
 )
 
-type fakeProvisioner struct{}
+// fakeProvisioner returns a minimal ObjectBucket from Provision and Grant. If err is set, it is
+// returned by every method other than GenerateUserID instead.
+type fakeProvisioner struct {
+	err error
+}
 
 var _ api.Provisioner = &fakeProvisioner{}
 
@@ -40,7 +44,10 @@ func (p *fakeProvisioner) Provision(options *api.BucketOptions) (*v1alpha1.Objec
 	if options == nil || options.ObjectBucketClaim == nil {
 		return nil, fmt.Errorf("got nil ptr")
 	}
-	return &v1alpha1.ObjectBucket{}, nil
+	if p.err != nil {
+		return nil, p.err
+	}
+	return newFakeObjectBucket(options), nil
 }
 
 // Grant provides a simple method for testing purposes
@@ -48,7 +55,10 @@ func (p *fakeProvisioner) Grant(options *api.BucketOptions) (*v1alpha1.ObjectBuc
 	if options == nil || options.ObjectBucketClaim == nil {
 		return nil, fmt.Errorf("got nil ptr")
 	}
-	return &v1alpha1.ObjectBucket{}, nil
+	if p.err != nil {
+		return nil, p.err
+	}
+	return newFakeObjectBucket(options), nil
 }
 
 // Delete provides a simple method for testing purposes
@@ -56,6 +66,9 @@ func (p *fakeProvisioner) Delete(ob *v1alpha1.ObjectBucket) (err error) {
 	if ob == nil {
 		err = fmt.Errorf("got nil object bucket pointer")
 	}
+	if p.err != nil {
+		err = p.err
+	}
 	return err
 }
 
@@ -64,5 +77,23 @@ func (p *fakeProvisioner) Revoke(ob *v1alpha1.ObjectBucket) (err error) {
 	if ob == nil {
 		err = fmt.Errorf("got nil object bucket pointer")
 	}
+	if p.err != nil {
+		err = p.err
+	}
 	return err
 }
+
+func newFakeObjectBucket(options *api.BucketOptions) *v1alpha1.ObjectBucket {
+	return &v1alpha1.ObjectBucket{
+		Spec: v1alpha1.ObjectBucketSpec{
+			Connection: &v1alpha1.Connection{
+				Endpoint: &v1alpha1.Endpoint{
+					BucketName: options.BucketName,
+				},
+				Authentication: &v1alpha1.Authentication{
+					AccessKeys: &v1alpha1.AccessKeys{},
+				},
+			},
+		},
+	}
+}
@@ -19,19 +19,31 @@ package provisioner
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
+	"unicode"
 
+	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+	liberrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
 )
 
 func makeObjectReference(claim *v1alpha1.ObjectBucketClaim) *corev1.ObjectReference {
@@ -86,8 +98,9 @@ func bucketIsOwnedByClaim(obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBu
 	return false
 }
 
-func shouldProvision(obc *v1alpha1.ObjectBucketClaim) bool {
-	logD.Info("checking OBC for OB name, this indicates provisioning is complete", obc.Name)
+func shouldProvision(ctx context.Context, obc *v1alpha1.ObjectBucketClaim) bool {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("checking OBC for OB name, this indicates provisioning is complete", obc.Name)
 	if obc.Spec.ObjectBucketName != "" && obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
 		log.Info("provisioning already completed", "ObjectBucket", obc.Spec.ObjectBucketName)
 		return false
@@ -95,22 +108,23 @@ func shouldProvision(obc *v1alpha1.ObjectBucketClaim) bool {
 	return true
 }
 
-func claimRefForKey(key string, c versioned.Interface) (*corev1.ObjectReference, error) {
-	claim, err := claimForKey(key, c)
+func claimRefForKey(ctx context.Context, key string, c versioned.Interface) (*corev1.ObjectReference, error) {
+	claim, err := claimForKey(ctx, key, c)
 	if err != nil {
 		return nil, err
 	}
 	return makeObjectReference(claim), nil
 }
 
-func claimForKey(key string, c versioned.Interface) (obc *v1alpha1.ObjectBucketClaim, err error) {
-	logD.Info("getting claim for key")
+func claimForKey(ctx context.Context, key string, c versioned.Interface) (obc *v1alpha1.ObjectBucketClaim, err error) {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("getting claim for key")
 
 	ns, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
 		return nil, err
 	}
-	return c.ObjectbucketV1alpha1().ObjectBucketClaims(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	return c.ObjectbucketV1alpha1().ObjectBucketClaims(ns).Get(ctx, name, metav1.GetOptions{})
 }
 
 // Return true if this storage class is for a new bucket vs an existing bucket.
@@ -119,9 +133,10 @@ func isNewBucketByStorageClass(sc *storagev1.StorageClass) bool {
 }
 
 // Return true if this OB is for a new bucket vs an existing bucket.
-func isNewBucketByObjectBucket(c kubernetes.Interface, ob *v1alpha1.ObjectBucket) bool {
+func isNewBucketByObjectBucket(ctx context.Context, c kubernetes.Interface, ob *v1alpha1.ObjectBucket) bool {
+	log := logr.FromContextOrDiscard(ctx)
 	// temp: get bucket name from OB's storage class
-	class, err := storageClassForObjectBucket(ob, c)
+	class, err := storageClassForObjectBucket(ctx, ob, c)
 	if err != nil || class == nil {
 		log.Error(err, "unable to get StorageClass of ObjectBucket")
 		return false
@@ -129,89 +144,281 @@ func isNewBucketByObjectBucket(c kubernetes.Interface, ob *v1alpha1.ObjectBucket
 	return len(class.Parameters[v1alpha1.StorageClassBucket]) == 0
 }
 
+// composeConfigMapName returns the name of the claim's ConfigMap. Claims which were bound before
+// the name was set in their spec use the claim's name.
 func composeConfigMapName(obc *v1alpha1.ObjectBucketClaim) string {
+	if obc.Spec.ConfigMapName != "" {
+		return obc.Spec.ConfigMapName
+	}
 	return obc.Name
 }
 
+// composeSecretName returns the name of the claim's Secret. Claims which were bound before the
+// name was set in their spec use the claim's name.
 func composeSecretName(obc *v1alpha1.ObjectBucketClaim) string {
+	if obc.Spec.SecretName != "" {
+		return obc.Spec.SecretName
+	}
 	return obc.Name
 }
 
-func configMapForClaimKey(key string, c kubernetes.Interface) (*corev1.ConfigMap, error) {
-	logD.Info("getting configMap for key", "key", key)
-	ns, name, err := cache.SplitMetaNamespaceKey(key)
-	if err != nil {
-		return nil, err
+// composeBindingSecretName returns the name of the claim's servicebinding.io binding Secret.
+func composeBindingSecretName(obc *v1alpha1.ObjectBucketClaim) string {
+	return composeSecretName(obc) + "-binding"
+}
+
+// serviceBindingEnabled returns whether the serviceBinding parameter of the storage class is true.
+func serviceBindingEnabled(class *storagev1.StorageClass) (bool, error) {
+	if class == nil {
+		return false, nil
+	}
+	value, ok := class.Parameters[v1alpha1.StorageClassServiceBinding]
+	if !ok {
+		return false, nil
 	}
-	cm, err := c.CoreV1().ConfigMaps(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	enabled, err := strconv.ParseBool(value)
 	if err != nil {
-		return nil, err
+		return false, liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+			fmt.Sprintf("storage class parameter %q must be a boolean, got %q", v1alpha1.StorageClassServiceBinding, value))
 	}
-	return cm, nil
+	return enabled, nil
 }
 
-func secretForClaimKey(key string, c kubernetes.Interface) (sec *corev1.Secret, err error) {
-	logD.Info("getting secret for key", "key", key)
-	ns, name, err := cache.SplitMetaNamespaceKey(key)
-	if err != nil {
-		return nil, err
-	}
-	sec, err = c.CoreV1().Secrets(ns).Get(context.TODO(), name, metav1.GetOptions{})
-	if err != nil {
-		return nil, err
+// bindingReference returns the reference to the claim's binding Secret for its status, or nil if
+// service bindings are disabled. The parameter is validated when the Secret is written.
+func bindingReference(obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) *corev1.LocalObjectReference {
+	if enabled, _ := serviceBindingEnabled(class); !enabled {
+		return nil
 	}
-	return
+	return &corev1.LocalObjectReference{Name: composeBindingSecretName(obc)}
 }
 
-func setObjectBucketName(ob *v1alpha1.ObjectBucket, key string) {
-	obName, err := objectBucketNameFromClaimKey(key)
-	if err != nil {
-		return
+// setGeneratedObjectNames sets the names of the claim's Secret and ConfigMap in its spec, unless
+// already set, from the storage class's name templates or to the claim's name. Like the bucket
+// name, the names are stored in the spec so that they do not change with the storage class. Claims
+// which were bound before the names were stored keep the claim's name, under which their Secret
+// and ConfigMap were written.
+func setGeneratedObjectNames(obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass, bound bool) error {
+	for _, n := range []struct {
+		name  *string
+		param string
+	}{
+		{&obc.Spec.SecretName, v1alpha1.StorageClassSecretName},
+		{&obc.Spec.ConfigMapName, v1alpha1.StorageClassConfigMapName},
+	} {
+		if *n.name != "" {
+			continue
+		}
+		tmpl, ok := class.Parameters[n.param]
+		if !ok || bound {
+			*n.name = obc.Name
+			continue
+		}
+		name, err := executeNameTemplate(tmpl, obc)
+		if err != nil {
+			return liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+				fmt.Sprintf("storage class parameter %q: %v", n.param, err))
+		}
+		*n.name = name
 	}
-	ob.Name = obName
+	return nil
 }
 
-func objectBucketNameFromClaimKey(key string) (string, error) {
-	ns, name, err := cache.SplitMetaNamespaceKey(key)
+// executeNameTemplate executes the Go template tmpl on the claim and validates the result as an
+// object name.
+func executeNameTemplate(tmpl string, obc *v1alpha1.ObjectBucketClaim) (string, error) {
+	t, err := template.New("name").Option("missingkey=error").Parse(tmpl)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf(objectBucketNameFormat, ns, name), nil
+	var b strings.Builder
+	if err = t.Execute(&b, obc); err != nil {
+		return "", err
+	}
+	name := b.String()
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// keyMapping returns the key renames of the claim's Secret or ConfigMap: those of the storage
+// class parameter param, given as comma separated FROM=TO pairs, overridden by those of the claim.
+func keyMapping(claimMapping map[string]string, class *storagev1.StorageClass, param string) (map[string]string, error) {
+	mapping := map[string]string{}
+	if value := strings.TrimSpace(class.Parameters[param]); value != "" {
+		for _, pair := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return nil, liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+					fmt.Sprintf("storage class parameter %q: %q is not a FROM=TO pair", param, pair))
+			}
+			mapping[kv[0]] = kv[1]
+		}
+	}
+	for from, to := range claimMapping {
+		mapping[from] = to
+	}
+	return mapping, nil
+}
+
+// secretKeyMapping and configMapKeyMapping return the key renames of the claim's Secret and
+// ConfigMap.
+func secretKeyMapping(obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (map[string]string, error) {
+	return keyMapping(obc.Spec.SecretKeyMapping, class, v1alpha1.StorageClassSecretKeyMapping)
 }
 
-func composeBucketName(obc *v1alpha1.ObjectBucketClaim) (string, error) {
+func configMapKeyMapping(obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (map[string]string, error) {
+	return keyMapping(obc.Spec.ConfigMapKeyMapping, class, v1alpha1.StorageClassConfigMapKeyMapping)
+}
+
+// remapKeys returns data with its keys renamed by mapping. Keys without a mapping are kept. It is
+// an error for two keys to end up with the same name.
+func remapKeys(data, mapping map[string]string) (map[string]string, error) {
+	if len(mapping) == 0 {
+		return data, nil
+	}
+	remapped := make(map[string]string, len(data))
+	for k, v := range data {
+		if to, ok := mapping[k]; ok {
+			k = to
+		}
+		if _, dup := remapped[k]; dup {
+			return nil, liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+				fmt.Sprintf("key mapping results in duplicate key %q", k))
+		}
+		remapped[k] = v
+	}
+	return remapped, nil
+}
+
+// mappedKey returns the name key is written under.
+func mappedKey(key string, mapping map[string]string) string {
+	if to, ok := mapping[key]; ok {
+		return to
+	}
+	return key
+}
+
+func configMapForClaim(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, c kubernetes.Interface) (*corev1.ConfigMap, error) {
+	log := logr.FromContextOrDiscard(ctx)
+	name := composeConfigMapName(obc)
+	log.V(1).Info("getting configMap for claim", "name", name)
+	return c.CoreV1().ConfigMaps(obc.Namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func secretForClaim(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, c kubernetes.Interface) (*corev1.Secret, error) {
+	log := logr.FromContextOrDiscard(ctx)
+	name := composeSecretName(obc)
+	log.V(1).Info("getting secret for claim", "name", name)
+	return c.CoreV1().Secrets(obc.Namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// objectBucketNameForClaim returns the name of the OB of a claim which is not bound yet. The name
+// is derived from the claim's UID, rather than its namespace and name, so that claims such as a-b/c
+// and a/b-c do not share an OB, and the name never exceeds the name length limit.
+func objectBucketNameForClaim(obc *v1alpha1.ObjectBucketClaim) string {
+	return objectBucketNamePrefix + string(obc.UID)
+}
+
+// claimRefMatches returns true if ref refers to the claim. References without a UID, written by
+// earlier versions of the library, match any claim of the same namespace and name.
+func claimRefMatches(ref *corev1.ObjectReference, obc *v1alpha1.ObjectBucketClaim) bool {
+	if ref == nil || ref.Namespace != obc.Namespace || ref.Name != obc.Name {
+		return false
+	}
+	return ref.UID == "" || ref.UID == obc.UID
+}
+
+// claimIndexFunc indexes OBs by the key of the claim they are bound to.
+func claimIndexFunc(obj interface{}) ([]string, error) {
+	ob, ok := obj.(*v1alpha1.ObjectBucket)
+	if !ok || ob.Spec.ClaimRef == nil || ob.Spec.ClaimRef.Name == "" {
+		return nil, nil
+	}
+	return []string{ob.Spec.ClaimRef.Namespace + "/" + ob.Spec.ClaimRef.Name}, nil
+}
+
+func composeBucketName(obc *v1alpha1.ObjectBucketClaim, rules api.BucketNameRules) (string, error) {
 	if obc.Spec.BucketName == "" && obc.Spec.GenerateBucketName == "" {
 		return "", fmt.Errorf("expected either bucketName or generateBucketName defined")
 	}
 	bucketName := obc.Spec.BucketName
 	if bucketName == "" {
-		bucketName = generateBucketName(obc.Spec.GenerateBucketName)
+		bucketName = generateBucketName(obc.Spec.GenerateBucketName, rules)
 	}
 	return bucketName, nil
 }
 
-const (
-	maxNameLen     = 63
-	uuidSuffixLen  = 36
-	maxBaseNameLen = maxNameLen - uuidSuffixLen
-)
+// validateBucketName returns a permanent error if name breaks the rules, since retrying cannot
+// fix the name.
+func validateBucketName(name string, rules api.BucketNameRules) error {
+	if errs := rules.Validate(name); len(errs) > 0 {
+		return liberrors.NewPermanentError(liberrors.ReasonInvalidBucketName,
+			fmt.Sprintf("invalid bucket name %q: %s", name, strings.Join(errs, ", ")))
+	}
+	return nil
+}
+
+// uuidSuffixLen is the length of the UUID appended to generated bucket names
+const uuidSuffixLen = 36
+
+// maxBucketNameRegenerations is how many times a generated bucket name which is taken in the object
+// store is replaced before the claim fails
+const maxBucketNameRegenerations = 5
 
-func generateBucketName(prefix string) string {
-	if len(prefix) >= maxBaseNameLen {
-		prefix = prefix[:maxBaseNameLen-1]
+// generateBucketName returns a unique bucket name, prefixed by the normalized prefix. The prefix is
+// truncated to fit the maximum length of the rules, and stripped of trailing characters other than
+// letters and digits, so that the separating hyphen does not follow another hyphen or a dot.
+func generateBucketName(prefix string, rules api.BucketNameRules) string {
+	prefix = generatedBucketNamePrefix(prefix, rules)
+	if prefix == "" {
+		return uuid.New().String()
 	}
 	return fmt.Sprintf("%s-%s", prefix, uuid.New())
 }
 
-func storageClassForClaim(c kubernetes.Interface, obc *v1alpha1.ObjectBucketClaim) (*storagev1.StorageClass, error) {
+// generatedBucketNamePrefix returns the prefix, normalized, truncated and stripped, which
+// generateBucketName puts in front of the UUID.
+func generatedBucketNamePrefix(prefix string, rules api.BucketNameRules) string {
+	prefix = rules.NormalizePrefix(prefix)
+	if maxPrefixLen := rules.MaxLength() - uuidSuffixLen - 1; len(prefix) > maxPrefixLen {
+		if maxPrefixLen < 0 {
+			maxPrefixLen = 0
+		}
+		prefix = prefix[:maxPrefixLen]
+	}
+	return strings.TrimRightFunc(prefix, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// isGeneratedBucketName returns true if name was generated by generateBucketName from prefix,
+// rather than chosen by the user.
+func isGeneratedBucketName(name, prefix string, rules api.BucketNameRules) bool {
+	if prefix == "" {
+		return false
+	}
+	if p := generatedBucketNamePrefix(prefix, rules); p != "" {
+		if !strings.HasPrefix(name, p+"-") {
+			return false
+		}
+		name = strings.TrimPrefix(name, p+"-")
+	}
+	_, err := uuid.Parse(name)
+	return err == nil && len(name) == uuidSuffixLen
+}
+
+func storageClassForClaim(ctx context.Context, c kubernetes.Interface, obc *v1alpha1.ObjectBucketClaim) (*storagev1.StorageClass, error) {
+	log := logr.FromContextOrDiscard(ctx)
 	if obc == nil {
 		return nil, fmt.Errorf("got nil ObjectBucketClaim pointer")
 	}
 	if obc.Spec.StorageClassName == "" {
 		return nil, fmt.Errorf("no StorageClass defined for ObjectBucketClaim \"%s/%s\"", obc.Namespace, obc.Name)
 	}
-	logD.Info("getting ObjectBucketClaim's StorageClass")
-	class, err := c.StorageV1().StorageClasses().Get(context.TODO(), obc.Spec.StorageClassName, metav1.GetOptions{})
+	log.V(1).Info("getting ObjectBucketClaim's StorageClass")
+	class, err := c.StorageV1().StorageClasses().Get(ctx, obc.Spec.StorageClassName, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error getting StorageClass %q: %v", obc.Spec.StorageClassName, err)
 	}
@@ -219,15 +426,16 @@ func storageClassForClaim(c kubernetes.Interface, obc *v1alpha1.ObjectBucketClai
 	return class, nil
 }
 
-func storageClassForObjectBucket(ob *v1alpha1.ObjectBucket, c kubernetes.Interface) (*storagev1.StorageClass, error) {
+func storageClassForObjectBucket(ctx context.Context, ob *v1alpha1.ObjectBucket, c kubernetes.Interface) (*storagev1.StorageClass, error) {
+	log := logr.FromContextOrDiscard(ctx)
 	if ob == nil {
 		return nil, fmt.Errorf("got nil ObjectBucket pointer")
 	}
 	if ob.Spec.StorageClassName == "" {
 		return nil, fmt.Errorf("no StorageClass defined for ObjectBucket %q", ob.Name)
 	}
-	logD.Info("getting ObjectBucket's storage class", "name", ob.Spec.StorageClassName)
-	class, err := c.StorageV1().StorageClasses().Get(context.TODO(), ob.Spec.StorageClassName, metav1.GetOptions{})
+	log.V(1).Info("getting ObjectBucket's storage class", "name", ob.Spec.StorageClassName)
+	class, err := c.StorageV1().StorageClasses().Get(ctx, ob.Spec.StorageClassName, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("error getting StorageClass %q: %v", ob.Spec.StorageClassName, err)
 	}
@@ -236,7 +444,78 @@ func storageClassForObjectBucket(ob *v1alpha1.ObjectBucket, c kubernetes.Interfa
 	return class, nil
 }
 
-func addLabels(obj metav1.Object, newLabels map[string]string) {
+// unwrapProvisioner returns the Provisioner adapted by api.AdaptProvisioner, or p itself, so that
+// the optional interfaces implemented by the provisioner can be detected.
+func unwrapProvisioner(p api.ProvisionerV2) interface{} {
+	if w, ok := p.(interface{ Unwrap() api.Provisioner }); ok {
+		return w.Unwrap()
+	}
+	return p
+}
+
+// libraryReclaimPolicies maps the reclaim policies defined by the library to the provisioner
+// operations carrying them out, as labeled in metrics.
+var libraryReclaimPolicies = map[corev1.PersistentVolumeReclaimPolicy]string{
+	v1alpha1.ReclaimPolicyErase:   operationErase,
+	v1alpha1.ReclaimPolicySuspend: operationSuspend,
+	v1alpha1.ReclaimPolicyArchive: operationArchive,
+}
+
+// reclaimFunc returns the method of the provisioner p carrying out the library defined reclaim
+// policy, or nil if p does not implement the policy's optional interface.
+func reclaimFunc(p interface{}, policy corev1.PersistentVolumeReclaimPolicy) func(context.Context, *v1alpha1.ObjectBucket) error {
+	switch policy {
+	case v1alpha1.ReclaimPolicyErase:
+		if e, ok := p.(api.Eraser); ok {
+			return e.Erase
+		}
+	case v1alpha1.ReclaimPolicySuspend:
+		if s, ok := p.(api.Suspender); ok {
+			return s.Suspend
+		}
+	case v1alpha1.ReclaimPolicyArchive:
+		if a, ok := p.(api.Archiver); ok {
+			return a.Archive
+		}
+	}
+	return nil
+}
+
+// reclaimPolicyForClass returns the reclaim policy of the new buckets of the class, set by its
+// reclaimPolicy parameter or else by its reclaimPolicy field.
+func reclaimPolicyForClass(class *storagev1.StorageClass) (*corev1.PersistentVolumeReclaimPolicy, error) {
+	value, ok := class.Parameters[v1alpha1.StorageClassReclaimPolicy]
+	if !ok {
+		return class.ReclaimPolicy, nil
+	}
+	policy := corev1.PersistentVolumeReclaimPolicy(value)
+	if _, ok = libraryReclaimPolicies[policy]; !ok && policy != corev1.PersistentVolumeReclaimDelete && policy != corev1.PersistentVolumeReclaimRetain {
+		return nil, liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+			fmt.Sprintf("storage class parameter %q must be one of Delete, Retain, Erase, Suspend or Archive, got %q", v1alpha1.StorageClassReclaimPolicy, value))
+	}
+	return &policy, nil
+}
+
+// additionalConfigChanged returns true if the claim's additionalConfig differs from the config
+// last applied to the bucket. Nil and empty configs are equal.
+func additionalConfigChanged(ob *v1alpha1.ObjectBucket, obc *v1alpha1.ObjectBucketClaim) bool {
+	if len(ob.Status.AppliedAdditionalConfig) == 0 && len(obc.Spec.AdditionalConfig) == 0 {
+		return false
+	}
+	return !reflect.DeepEqual(ob.Status.AppliedAdditionalConfig, obc.Spec.AdditionalConfig)
+}
+
+func newCondition(conditionType string, status metav1.ConditionStatus, reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+func addLabels(ctx context.Context, obj metav1.Object, newLabels map[string]string) {
+	log := logr.FromContextOrDiscard(ctx)
 	labels := obj.GetLabels()
 	if labels == nil {
 		labels = make(map[string]string)
@@ -286,3 +565,94 @@ func labelValue(v string) string {
 	}
 	return strings.Replace(v, "/", "-", -1)
 }
+
+// credentialsStatus returns the status of credentials issued now, or nil if the credentials do not
+// expire.
+func credentialsStatus(auth *v1alpha1.Authentication) *v1alpha1.CredentialsStatus {
+	if auth == nil || auth.ExpirationTime == nil {
+		return nil
+	}
+	now := metav1.Now()
+	return &v1alpha1.CredentialsStatus{
+		IssueTime:      &now,
+		ExpirationTime: auth.ExpirationTime.DeepCopy(),
+	}
+}
+
+// refreshTime returns when credentials are due to be refreshed: window before they expire, or
+// halfway through their lifetime if that is earlier. The zero time is returned for credentials
+// which do not expire.
+func refreshTime(credentials *v1alpha1.CredentialsStatus, window time.Duration) time.Time {
+	if credentials == nil || credentials.ExpirationTime == nil {
+		return time.Time{}
+	}
+	expiration := credentials.ExpirationTime.Time
+	if credentials.IssueTime != nil {
+		if half := expiration.Sub(credentials.IssueTime.Time) / 2; half < window {
+			window = half
+		}
+	}
+	return expiration.Add(-window)
+}
+
+// defaultRotationOverlap is how long the previous credentials stay valid after a rotation, unless
+// set by the storage class.
+const defaultRotationOverlap = time.Hour
+
+// rotationParameters returns the credential rotation interval and overlap window set by the
+// storage class. An interval of 0 disables scheduled rotation.
+func rotationParameters(class *storagev1.StorageClass) (interval, overlap time.Duration, err error) {
+	parse := func(param string, def time.Duration) (time.Duration, error) {
+		value, ok := class.Parameters[param]
+		if !ok {
+			return def, nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return 0, liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+				fmt.Sprintf("storage class parameter %q must be a non-negative duration, got %q", param, value))
+		}
+		return d, nil
+	}
+	if interval, err = parse(v1alpha1.StorageClassRotationInterval, 0); err != nil {
+		return 0, 0, err
+	}
+	if overlap, err = parse(v1alpha1.StorageClassRotationOverlap, defaultRotationOverlap); err != nil {
+		return 0, 0, err
+	}
+	return interval, overlap, nil
+}
+
+// secretValue returns the value of key in secret. Secrets written with StringData only carry Data
+// once they went through the API server.
+func secretValue(secret *corev1.Secret, key string) string {
+	if v, ok := secret.Data[key]; ok {
+		return string(v)
+	}
+	return secret.StringData[key]
+}
+
+// provisionerNames returns the names of the provisioners in sorted order.
+func provisionerNames(provisioners map[string]api.ProvisionerV2) []string {
+	names := make([]string, 0, len(provisioners))
+	for name := range provisioners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// provisionerSelector selects the objects labeled as generated by any of the named provisioners. It
+// selects nothing if a name does not make a valid label value.
+func provisionerSelector(names []string) labels.Selector {
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, labelValue(name))
+	}
+	req, err := labels.NewRequirement(provisionerLabelKey, selection.In, values)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("error selecting objects of provisioners %v: %v", names, err))
+		return labels.Nothing()
+	}
+	return labels.NewSelector().Add(*req)
+}
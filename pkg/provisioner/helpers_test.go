@@ -20,20 +20,22 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 
-	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/kubernetes/fake"
 
+	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	externalFake "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned/fake"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+	liberrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
 )
 
 const (
@@ -46,6 +48,7 @@ const (
 var objMeta = metav1.ObjectMeta{
 	Namespace: testNamespace,
 	Name:      testName,
+	UID:       "6f1e4a8c-3c5d-4a61-9d2b-0f7e2c9a1b34",
 }
 
 // test global provisioner fields
@@ -111,7 +114,7 @@ func Test_objectBucketClaimReconciler_shouldProvision(t *testing.T) {
 		}
 
 		t.Run(tt.name, func(t *testing.T) {
-			if got := shouldProvision(tt.args.obc); got != tt.want {
+			if got := shouldProvision(context.TODO(), tt.args.obc); got != tt.want {
 				t.Errorf("want = %v, got %v", tt.want, got)
 			}
 		})
@@ -164,7 +167,7 @@ func Test_objectBucketClaimReconciler_claimFromKey(t *testing.T) {
 		}
 
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := claimForKey(tt.args.key, ec)
+			got, err := claimForKey(context.TODO(), tt.args.key, ec)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("wantErr %v, error = %v", tt.wantErr, err)
 				return
@@ -280,7 +283,7 @@ func TestStorageClassForClaim(t *testing.T) {
 				}
 			}
 
-			got, err := storageClassForClaim(tt.args.client, tt.args.obc)
+			got, err := storageClassForClaim(context.TODO(), tt.args.client, tt.args.obc)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("wantErr %v, error = %v ", tt.wantErr, err)
 				return
@@ -297,42 +300,81 @@ func TestGenerateBucketName(t *testing.T) {
 		prefix string
 	}
 	tests := []struct {
-		name    string
-		args    args
-		wanterr bool
+		name       string
+		args       args
+		wantPrefix string
 	}{
 		{
 			name: "empty name",
 			args: args{
 				prefix: "",
 			},
-			wanterr: false,
+			wantPrefix: "",
 		},
 		{
 			name: "below max name",
 			args: args{
 				prefix: "foobar",
 			},
-			wanterr: false,
+			wantPrefix: "foobar-",
 		},
 		{
 			name: "over max name length name",
 			args: args{
-				prefix: rand.String(maxNameLen * 2),
+				prefix: strings.Repeat("a", 63*2),
+			},
+			wantPrefix: strings.Repeat("a", 26) + "-",
+		},
+		{
+			name: "truncated before a hyphen",
+			args: args{
+				prefix: strings.Repeat("a", 25) + "-bucket",
+			},
+			wantPrefix: strings.Repeat("a", 25) + "-",
+		},
+		{
+			name: "uppercase letters and underscores",
+			args: args{
+				prefix: "_My_Photos",
+			},
+			wantPrefix: "my-photos-",
+		},
+		{
+			name: "runs of dots",
+			args: args{
+				prefix: "my..photos",
+			},
+			wantPrefix: "my.photos-",
+		},
+		{
+			name: "runs created by replacing characters",
+			args: args{
+				prefix: "my_.photos__2024",
+			},
+			wantPrefix: "my-photos-2024-",
+		},
+		{
+			name: "leading and trailing dots",
+			args: args{
+				prefix: "..photos..",
 			},
-			wanterr: false,
+			wantPrefix: "photos-",
 		},
 	}
 
-	const pattern = `.*-[a-z0-9]{8}(-[a-z0-9]{4}){3}-[a-z0-9]{12}`
+	const pattern = `[a-z0-9]{8}(-[a-z0-9]{4}){3}-[a-z0-9]{12}$`
 
+	rules := api.S3BucketNameRules{}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := generateBucketName(tt.args.prefix)
-			if len(got) > maxNameLen {
-				t.Errorf("wanted len <= %d, got len %d", maxNameLen, len(got))
+			got := generateBucketName(tt.args.prefix, rules)
+			if errs := rules.Validate(got); len(errs) > 0 {
+				t.Errorf("invalid name %q: %v", got, errs)
+			}
+			if !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("want prefix %q, got %q", tt.wantPrefix, got)
 			}
-			if match, err := regexp.MatchString(pattern, got); err != nil {
+			if match, err := regexp.MatchString("^"+regexp.QuoteMeta(tt.wantPrefix)+pattern, got); err != nil {
 				t.Errorf("error matching pattern: %v", err)
 			} else if !match {
 				t.Errorf("want match: %v, got %v", pattern, got)
@@ -341,6 +383,79 @@ func TestGenerateBucketName(t *testing.T) {
 	}
 }
 
+func Test_isGeneratedBucketName(t *testing.T) {
+	rules := api.S3BucketNameRules{}
+	tests := []struct {
+		name       string
+		bucketName string
+		prefix     string
+		want       bool
+	}{
+		{
+			name:       "generated from the prefix",
+			bucketName: generateBucketName("_My_Photos", rules),
+			prefix:     "_My_Photos",
+			want:       true,
+		},
+		{
+			name:       "generated from an empty normalized prefix",
+			bucketName: generateBucketName("-", rules),
+			prefix:     "-",
+			want:       true,
+		},
+		{
+			name:       "generated from another prefix",
+			bucketName: generateBucketName("videos", rules),
+			prefix:     "photos",
+		},
+		{
+			name:       "explicit name",
+			bucketName: "photos-bucket",
+			prefix:     "photos",
+		},
+		{
+			name:       "no prefix",
+			bucketName: generateBucketName("", rules),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isGeneratedBucketName(tt.bucketName, tt.prefix, rules); got != tt.want {
+				t.Errorf("isGeneratedBucketName(%q, %q) = %v, want %v", tt.bucketName, tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateBucketName(t *testing.T) {
+	tests := []struct {
+		name    string
+		bucket  string
+		wantErr bool
+	}{
+		{name: "valid", bucket: "photo-booth.example"},
+		{name: "too short", bucket: "ab", wantErr: true},
+		{name: "too long", bucket: strings.Repeat("a", 64), wantErr: true},
+		{name: "uppercase letters", bucket: "Photos", wantErr: true},
+		{name: "underscore", bucket: "photo_booth", wantErr: true},
+		{name: "trailing hyphen", bucket: "photos-", wantErr: true},
+		{name: "adjacent dots", bucket: "photos..booth", wantErr: true},
+		{name: "ip address", bucket: "192.168.1.1", wantErr: true},
+		{name: "reserved prefix", bucket: "xn--photos", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBucketName(tt.bucket, api.S3BucketNameRules{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateBucketName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && liberrors.PermanentReason(err) != liberrors.ReasonInvalidBucketName {
+				t.Errorf("validateBucketName() reason = %q, want %q", liberrors.PermanentReason(err), liberrors.ReasonInvalidBucketName)
+			}
+		})
+	}
+}
+
 func TestAddFinalizers(t *testing.T) {
 	type args struct {
 		obj           *v1alpha1.ObjectBucketClaim
@@ -431,3 +546,209 @@ func TestAddFinalizers(t *testing.T) {
 		})
 	}
 }
+
+func Test_refreshTime(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(d))
+		return &t
+	}
+	tests := []struct {
+		name        string
+		credentials *v1alpha1.CredentialsStatus
+		want        time.Time
+	}{
+		{
+			name: "no credentials",
+		},
+		{
+			name:        "credentials without expiration",
+			credentials: &v1alpha1.CredentialsStatus{IssueTime: at(0)},
+		},
+		{
+			name:        "long lived credentials",
+			credentials: &v1alpha1.CredentialsStatus{IssueTime: at(0), ExpirationTime: at(time.Hour)},
+			want:        now.Add(time.Hour - 5*time.Minute),
+		},
+		{
+			name:        "short lived credentials",
+			credentials: &v1alpha1.CredentialsStatus{IssueTime: at(0), ExpirationTime: at(4 * time.Minute)},
+			want:        now.Add(2 * time.Minute),
+		},
+		{
+			name:        "credentials without issue time",
+			credentials: &v1alpha1.CredentialsStatus{ExpirationTime: at(4 * time.Minute)},
+			want:        now.Add(-time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refreshTime(tt.credentials, 5*time.Minute); !got.Equal(tt.want) {
+				t.Errorf("refreshTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setGeneratedObjectNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          v1alpha1.ObjectBucketClaimSpec
+		params        map[string]string
+		bound         bool
+		wantSecret    string
+		wantConfigMap string
+		wantErr       bool
+	}{
+		{
+			name:          "defaults to the claim name",
+			wantSecret:    testName,
+			wantConfigMap: testName,
+		},
+		{
+			name: "storage class templates",
+			params: map[string]string{
+				v1alpha1.StorageClassSecretName:    "{{ .Name }}-credentials",
+				v1alpha1.StorageClassConfigMapName: "{{ .Name }}-endpoint",
+			},
+			wantSecret:    testName + "-credentials",
+			wantConfigMap: testName + "-endpoint",
+		},
+		{
+			name: "claim bound before the names were stored",
+			params: map[string]string{
+				v1alpha1.StorageClassSecretName:    "{{ .Name }}-credentials",
+				v1alpha1.StorageClassConfigMapName: "{{ .Name }}-endpoint",
+			},
+			bound:         true,
+			wantSecret:    testName,
+			wantConfigMap: testName,
+		},
+		{
+			name: "names set by the claim",
+			spec: v1alpha1.ObjectBucketClaimSpec{SecretName: "my-secret", ConfigMapName: "my-configmap"},
+			params: map[string]string{
+				v1alpha1.StorageClassSecretName: "{{ .Name }}-credentials",
+			},
+			wantSecret:    "my-secret",
+			wantConfigMap: "my-configmap",
+		},
+		{
+			name:    "invalid template",
+			params:  map[string]string{v1alpha1.StorageClassSecretName: "{{ .Name "},
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			params:  map[string]string{v1alpha1.StorageClassSecretName: "{{ .Name }}_credentials"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := &v1alpha1.ObjectBucketClaim{ObjectMeta: objMeta, Spec: tt.spec}
+			class := &storagev1.StorageClass{Parameters: tt.params}
+			err := setGeneratedObjectNames(obc, class, tt.bound)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setGeneratedObjectNames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if obc.Spec.SecretName != tt.wantSecret || obc.Spec.ConfigMapName != tt.wantConfigMap {
+				t.Errorf("names = %q and %q, want %q and %q", obc.Spec.SecretName, obc.Spec.ConfigMapName, tt.wantSecret, tt.wantConfigMap)
+			}
+		})
+	}
+}
+
+func Test_keyMapping(t *testing.T) {
+	tests := []struct {
+		name         string
+		param        string
+		claimMapping map[string]string
+		want         map[string]string
+		wantErr      bool
+	}{
+		{
+			name: "no mapping",
+			want: map[string]string{},
+		},
+		{
+			name:  "storage class mapping",
+			param: "BUCKET_URL=AWS_ENDPOINT_URL, BUCKET_REGION=AWS_REGION",
+			want:  map[string]string{"BUCKET_URL": "AWS_ENDPOINT_URL", "BUCKET_REGION": "AWS_REGION"},
+		},
+		{
+			name:         "claim mapping overrides storage class mapping",
+			param:        "BUCKET_URL=AWS_ENDPOINT_URL,BUCKET_REGION=AWS_REGION",
+			claimMapping: map[string]string{"BUCKET_URL": "S3_ENDPOINT"},
+			want:         map[string]string{"BUCKET_URL": "S3_ENDPOINT", "BUCKET_REGION": "AWS_REGION"},
+		},
+		{
+			name:    "malformed storage class mapping",
+			param:   "BUCKET_URL",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := &storagev1.StorageClass{Parameters: map[string]string{v1alpha1.StorageClassConfigMapKeyMapping: tt.param}}
+			got, err := keyMapping(tt.claimMapping, class, v1alpha1.StorageClassConfigMapKeyMapping)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("keyMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !cmp.Equal(tt.want, got) {
+				t.Errorf("keyMapping(): %s", cmp.Diff(tt.want, got))
+			}
+		})
+	}
+}
+
+func Test_reclaimPolicyForClass(t *testing.T) {
+	retain := corev1.PersistentVolumeReclaimRetain
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    corev1.PersistentVolumeReclaimPolicy
+		wantErr bool
+	}{
+		{
+			name: "defaults to the class's reclaimPolicy",
+			want: corev1.PersistentVolumeReclaimRetain,
+		},
+		{
+			name:   "library defined policy",
+			params: map[string]string{v1alpha1.StorageClassReclaimPolicy: "Erase"},
+			want:   v1alpha1.ReclaimPolicyErase,
+		},
+		{
+			name:   "overrides the class's reclaimPolicy",
+			params: map[string]string{v1alpha1.StorageClassReclaimPolicy: "Delete"},
+			want:   corev1.PersistentVolumeReclaimDelete,
+		},
+		{
+			name:    "unknown policy",
+			params:  map[string]string{v1alpha1.StorageClassReclaimPolicy: "Recycle"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := &storagev1.StorageClass{Parameters: tt.params, ReclaimPolicy: &retain}
+			got, err := reclaimPolicyForClass(class)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reclaimPolicyForClass() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !liberrors.IsPermanent(err) {
+					t.Errorf("reclaimPolicyForClass() error = %v, want a permanent error", err)
+				}
+				return
+			}
+			if got == nil || *got != tt.want {
+				t.Errorf("reclaimPolicyForClass() = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
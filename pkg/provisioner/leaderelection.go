@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// LeaderElectionConfig configures the Lease based leader election enabled by WithLeaderElection.
+type LeaderElectionConfig struct {
+	// LeaseName is the name of the Lease object. Defaults to the provisioner name with "/"
+	// replaced by "-".
+	LeaseName string
+	// LeaseNamespace is the namespace of the Lease object. Defaults to the namespace given to
+	// NewProvisioner and must be set if the provisioner watches all namespaces, or the namespaces
+	// chosen by WithNamespaceSelector.
+	LeaseNamespace string
+	// Identity uniquely identifies this replica. Defaults to the hostname followed by a UUID.
+	Identity string
+	// LeaseDuration is how long non-leaders wait before attempting to acquire the lease.
+	// Defaults to 15s.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader retries refreshing the lease before giving it up.
+	// Defaults to 10s.
+	RenewDeadline time.Duration
+	// RetryPeriod is how long replicas wait between attempts to acquire or renew the lease.
+	// Defaults to 2s.
+	RetryPeriod time.Duration
+}
+
+// setDefaults fills in unset fields of the config.
+func (cfg *LeaderElectionConfig) setDefaults(provisionerName, namespace string) error {
+	if cfg.LeaseName == "" {
+		cfg.LeaseName = strings.Replace(provisionerName, "/", "-", -1)
+	}
+	if cfg.LeaseNamespace == "" {
+		cfg.LeaseNamespace = namespace
+	}
+	if cfg.LeaseNamespace == "" {
+		return fmt.Errorf("leader election requires a lease namespace unless a namespace is given")
+	}
+	if cfg.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("error getting hostname for leader election identity: %v", err)
+		}
+		cfg.Identity = hostname + "_" + uuid.New().String()
+	}
+	if cfg.LeaseDuration == 0 {
+		cfg.LeaseDuration = defaultLeaseDuration
+	}
+	if cfg.RenewDeadline == 0 {
+		cfg.RenewDeadline = defaultRenewDeadline
+	}
+	if cfg.RetryPeriod == 0 {
+		cfg.RetryPeriod = defaultRetryPeriod
+	}
+	return nil
+}
+
+// runWithLeaderElection blocks until the lease is acquired and then calls run with a context
+// which is cancelled when the lease is lost or ctx is done. The lease is released on return.
+// An error is returned if the lease is lost while ctx is still active.
+func runWithLeaderElection(ctx context.Context, log logr.Logger, c kubernetes.Interface, cfg *LeaderElectionConfig, run func(context.Context) error) error {
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		cfg.LeaseNamespace,
+		cfg.LeaseName,
+		c.CoreV1(),
+		c.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: cfg.Identity})
+	if err != nil {
+		return fmt.Errorf("error creating leader election lock: %v", err)
+	}
+
+	leCtx, cancel := context.WithCancel(ctx)
+	elected := make(chan struct{})
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            cfg.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				close(elected)
+			},
+			OnStoppedLeading: cancel,
+			OnNewLeader: func(identity string) {
+				log.Info("leader elected", "lease", cfg.LeaseNamespace+"/"+cfg.LeaseName, "identity", identity)
+			},
+		},
+	})
+	if err != nil {
+		cancel()
+		return fmt.Errorf("error creating leader elector: %v", err)
+	}
+
+	electorDone := make(chan struct{})
+	go func() {
+		defer close(electorDone)
+		elector.Run(leCtx)
+	}()
+	// wait for the elector to release the lease before returning
+	defer func() {
+		cancel()
+		<-electorDone
+	}()
+
+	log.Info("waiting for leader election", "lease", cfg.LeaseNamespace+"/"+cfg.LeaseName, "identity", cfg.Identity)
+	select {
+	case <-elected:
+	case <-leCtx.Done():
+		return nil
+	}
+
+	log.Info("acquired leader election lease", "lease", cfg.LeaseNamespace+"/"+cfg.LeaseName)
+	err = run(leCtx)
+	if err == nil && ctx.Err() == nil {
+		err = fmt.Errorf("lost leader election lease %q", cfg.LeaseNamespace+"/"+cfg.LeaseName)
+	}
+	return err
+}
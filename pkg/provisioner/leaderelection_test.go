@@ -0,0 +1,111 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaderElectionConfig_setDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       LeaderElectionConfig
+		namespace string
+		wantName  string
+		wantNs    string
+		wantErr   bool
+	}{
+		{
+			name:      "defaults from provisioner",
+			namespace: testNamespace,
+			wantName:  "example.com-bucket",
+			wantNs:    testNamespace,
+		},
+		{
+			name:      "explicit lease",
+			cfg:       LeaderElectionConfig{LeaseName: "lease", LeaseNamespace: "lease-ns"},
+			namespace: testNamespace,
+			wantName:  "lease",
+			wantNs:    "lease-ns",
+		},
+		{
+			name:    "cluster scoped without lease namespace",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.setDefaults("example.com/bucket", tt.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setDefaults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.cfg.LeaseName != tt.wantName || tt.cfg.LeaseNamespace != tt.wantNs {
+				t.Errorf("setDefaults() lease = %s/%s, want %s/%s", tt.cfg.LeaseNamespace, tt.cfg.LeaseName, tt.wantNs, tt.wantName)
+			}
+			if tt.cfg.Identity == "" || tt.cfg.LeaseDuration != defaultLeaseDuration {
+				t.Errorf("setDefaults() did not default identity and durations: %+v", tt.cfg)
+			}
+		})
+	}
+}
+
+func Test_runWithLeaderElection(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	cfg := &LeaderElectionConfig{}
+	if err := cfg.setDefaults(provisionerName, testNamespace); err != nil {
+		t.Fatalf("setDefaults() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	errCh := make(chan error)
+	go func() {
+		errCh <- runWithLeaderElection(ctx, logr.Discard(), client, cfg, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return nil
+		})
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("controllers were not started after acquiring the lease")
+	}
+	lease, err := client.CoordinationV1().Leases(testNamespace).Get(context.TODO(), cfg.LeaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting lease: %v", err)
+	}
+	if *lease.Spec.HolderIdentity != cfg.Identity {
+		t.Errorf("lease holder = %q, want %q", *lease.Spec.HolderIdentity, cfg.Identity)
+	}
+
+	cancel()
+	if err = <-errCh; err != nil {
+		t.Errorf("runWithLeaderElection() error = %v, want nil after cancel", err)
+	}
+}
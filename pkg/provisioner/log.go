@@ -17,31 +17,15 @@ limitations under the License.
 package provisioner
 
 import (
-	"github.com/go-logr/logr"
-	"k8s.io/klog/v2/klogr"
-
-	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
-)
-
-var (
-	// log is a short lived variable that is overwritten each Reconcile() iteration with the current Request injected
-	// into it.  This is for convenience of identifying which log lines were generated by which request without having
-	// to pass the request to every method call.
-	log logr.Logger
+	"context"
 
-	// logD is a short lived variable that is overwritten each Reconcile() iteraion with the current Request injected
-	// into it.  This is for convenience of identifying which log lines were generated by which request without having
-	// to pass the request to every log method call.
-	logD logr.Logger
+	"github.com/go-logr/logr"
 )
 
-func init() {
-	log = klogr.New().WithName(api.Domain + "/claim-reconciler")
-	logD = log.V(1)
-}
-
-// setLoggerWith request overwrites log and logD with a new logger.  The passed in request is injected into the loggers.
-func setLoggersWithRequest(key string) {
-	log = klogr.New().WithValues("key", key)
-	logD = klogr.New().WithValues("key", key)
+// withRequestLogger returns a context carrying a logger derived from base with the request key
+// injected into it. This is for convenience of identifying which log lines were generated by which
+// request. Functions handling the request retrieve the logger with logr.FromContextOrDiscard, so
+// concurrent workers never share a logger.
+func withRequestLogger(ctx context.Context, base logr.Logger, key string) context.Context {
+	return logr.NewContext(ctx, base.WithValues("key", key))
 }
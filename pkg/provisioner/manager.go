@@ -18,14 +18,20 @@ package provisioner
 
 import (
 	"context"
-	"flag"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/validation"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
-	"k8s.io/klog/v2/klogr"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned"
 	informers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/informers/externalversions"
@@ -37,63 +43,170 @@ type Provisioner struct {
 	Name            string
 	Provisioner     api.Provisioner
 	claimController controller
-	informerFactory informers.SharedInformerFactory
-}
-
-func initLoggers() {
-	log = klogr.New().WithName(api.Domain + "/provisioner-manager")
-	logD = log.V(1)
-}
-
-func initFlags() {
-	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
-	klog.InitFlags(klogFlags)
-
-	flag.CommandLine.VisitAll(func(f *flag.Flag) {
-		kflag := klogFlags.Lookup(f.Name)
-		if kflag != nil {
-			val := f.Value.String()
-			kflag.Value.Set(val)
-		}
-	})
-	if !flag.Parsed() {
-		flag.Parse()
-	}
+	// informerFactories holds one factory per watched namespace, or a single cluster-wide factory
+	informerFactories []informers.SharedInformerFactory
+	// kubeInformerFactories watch the Secrets and ConfigMaps generated in the same namespaces
+	kubeInformerFactories []kubeinformers.SharedInformerFactory
+	// namespaceWatcher is nil unless namespaces are chosen with WithNamespaceSelector
+	namespaceWatcher *namespaceWatcher
+	// eventBroadcaster is nil if an event recorder was given with WithEventRecorder
+	eventBroadcaster record.EventBroadcaster
+	clientset        kubernetes.Interface
+	metrics          *metrics
+	// leaderElection is nil unless enabled with WithLeaderElection
+	leaderElection *LeaderElectionConfig
+	// metricsAddress is empty unless enabled with WithMetricsAddress
+	metricsAddress string
+	// webhook is nil unless enabled with WithWebhook
+	webhook        *WebhookConfig
+	claimValidator *claimValidator
+	log            logr.Logger
 }
 
 // NewProvisioner should be called by importers of this library to
 // instantiate a new provisioning obcController. This obcController will
 // respond to Add / Update / Delete events by calling the passed-in
 // provisioner's Provisioner and Delete methods.
-// The Provisioner will be restrict to operating only to the namespace given
+// The Provisioner will be restrict to operating only to the namespace given, or to all namespaces if
+// it is empty. More namespaces, or a namespace label selector, may be given as Options.
+// Optional behavior, such as leader election, is enabled by passing Options.
+// NewProvisioner does not parse or modify command line flags. Importers wishing to configure klog
+// through flags should register them with klog.InitFlags themselves, or pass a logger WithLogger.
 func NewProvisioner(
 	cfg *rest.Config,
 	provisionerName string,
 	provisioner api.Provisioner,
 	namespace string,
+	opts ...Option,
+) (*Provisioner, error) {
+	return NewProvisionerV2(cfg, provisionerName, api.AdaptProvisioner(provisioner), namespace, opts...)
+}
+
+// NewProvisionerV2 is like NewProvisioner for provisioners implementing the context aware
+// api.ProvisionerV2 interface. Shutting down the returned Provisioner cancels the context of
+// in-flight provisioner calls.
+func NewProvisionerV2(
+	cfg *rest.Config,
+	provisionerName string,
+	provisioner api.ProvisionerV2,
+	namespace string,
+	opts ...Option,
 ) (*Provisioner, error) {
+	return NewMultiProvisioner(cfg, map[string]api.ProvisionerV2{provisionerName: provisioner}, namespace, opts...)
+}
+
+// NewMultiProvisioner is like NewProvisionerV2 for several provisioners served by one controller,
+// e.g. the backends of an operator. Each OBC is dispatched to the provisioner named by its storage
+// class, while the informers, the work queue and the workers are shared. The returned Provisioner
+// is named after the provisioners joined by commas, and the lease of leader election after the
+// first of them in sorted order unless LeaderElectionConfig.LeaseName is set.
+func NewMultiProvisioner(
+	cfg *rest.Config,
+	provisioners map[string]api.ProvisionerV2,
+	namespace string,
+	opts ...Option,
+) (*Provisioner, error) {
+	if len(provisioners) == 0 {
+		return nil, fmt.Errorf("at least one provisioner is required")
+	}
+	names := provisionerNames(provisioners)
+	for _, name := range names {
+		if errs := validation.IsValidLabelValue(labelValue(name)); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid provisioner name %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	provisionerName := strings.Join(names, ",")
+
+	o := newOptions(opts...)
+	if o.workers < 1 {
+		return nil, fmt.Errorf("invalid number of workers %d, at least 1 is required", o.workers)
+	}
 
-	initFlags()
-	initLoggers()
+	namespaces := o.namespaces
+	if namespace != "" {
+		namespaces = append([]string{namespace}, namespaces...)
+	}
+	var selector labels.Selector
+	if o.namespaceSelector != "" {
+		if len(namespaces) > 0 {
+			return nil, fmt.Errorf("a namespace selector cannot be combined with namespaces %v", namespaces)
+		}
+		var err error
+		if selector, err = labels.Parse(o.namespaceSelector); err != nil {
+			return nil, fmt.Errorf("invalid namespace selector %q: %v", o.namespaceSelector, err)
+		}
+	}
+	if o.leaderElection != nil {
+		var leaseNamespace string
+		if len(namespaces) > 0 {
+			leaseNamespace = namespaces[0]
+		}
+		if err := o.leaderElection.setDefaults(names[0], leaseNamespace); err != nil {
+			return nil, err
+		}
+	}
 
 	libClientset := versioned.NewForConfigOrDie(cfg)
 	clientset := kubernetes.NewForConfigOrDie(cfg)
 
-	informerFactory := setupInformerFactory(libClientset, 0, namespace)
-
 	p := &Provisioner{
-		Name:            provisionerName,
-		informerFactory: informerFactory,
+		Name:           provisionerName,
+		clientset:      clientset,
+		leaderElection: o.leaderElection,
+		metricsAddress: o.metricsAddress,
+		webhook:        o.webhook,
+		log:            o.logger,
+	}
+	if o.webhook != nil {
+		p.claimValidator = newClaimValidator(names, o.webhook, o.nameRules, namespaces, selector, clientset, o.logger)
+	}
+	if o.recorder == nil {
+		p.eventBroadcaster = newEventBroadcaster(clientset, o.logger)
+		o.recorder = newEventRecorder(p.eventBroadcaster, provisionerName)
+	}
 
-		claimController: NewController(
-			provisionerName,
-			provisioner,
+	// With a namespace selector, the informers of the selected namespaces are run by a
+	// namespaceWatcher and the single factory only watches OBs.
+	if selector != nil {
+		p.informerFactories = []informers.SharedInformerFactory{setupInformerFactory(libClientset, o.resyncPeriod, "")}
+		claimController := newController(
+			provisioners,
 			clientset,
 			libClientset,
-			informerFactory.Objectbucket().V1alpha1().ObjectBucketClaims(),
-			informerFactory.Objectbucket().V1alpha1().ObjectBuckets()),
+			nil,
+			p.informerFactories[0].Objectbucket().V1alpha1().ObjectBuckets(),
+			o)
+		p.namespaceWatcher = newNamespaceWatcher(clientset, libClientset, selector, o.resyncPeriod, claimController, o.logger)
+		p.claimController = claimController
+		p.metrics = claimController.metrics
+		return p, nil
 	}
 
+	// An informer factory is created per namespace, or a single factory for all namespaces. OBs
+	// are cluster scoped so they are always watched through the first factory.
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for _, ns := range namespaces {
+		p.informerFactories = append(p.informerFactories, setupInformerFactory(libClientset, o.resyncPeriod, ns))
+		p.kubeInformerFactories = append(p.kubeInformerFactories, setupKubeInformerFactory(clientset, o.resyncPeriod, ns, provisionerSelector(names)))
+	}
+	claimController := newController(
+		provisioners,
+		clientset,
+		libClientset,
+		p.informerFactories[0].Objectbucket().V1alpha1().ObjectBucketClaims(),
+		p.informerFactories[0].Objectbucket().V1alpha1().ObjectBuckets(),
+		o)
+	for _, factory := range p.informerFactories[1:] {
+		claimController.addClaimInformer(factory.Objectbucket().V1alpha1().ObjectBucketClaims())
+	}
+	for _, factory := range p.kubeInformerFactories {
+		claimController.addOwnedResourceInformers(factory.Core().V1().Secrets(), factory.Core().V1().ConfigMaps())
+	}
+	p.claimController = claimController
+	p.metrics = claimController.metrics
+
 	return p, nil
 }
 
@@ -114,40 +227,60 @@ func (p *Provisioner) SetLabels(labels map[string]string) []string {
 	return nil
 }
 
-// Run starts the claim and bucket controllers.
-func (p *Provisioner) Run(stopCh <-chan struct{}) (err error) {
-	defer klog.Flush()
-	log.Info("starting provisioner", "name", p.Name)
-
-	p.informerFactory.Start(stopCh)
-
+// Run starts the claim and bucket controllers and blocks until stopCh is closed.
+func (p *Provisioner) Run(stopCh <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
-		err = p.claimController.Start(stopCh)
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
-	<-stopCh
-	return
+	return p.RunWithContext(ctx)
 }
 
-func (p *Provisioner) RunWithContext(context context.Context) (err error) {
-	stopCh := make(chan struct{})
-
+// RunWithContext starts the claim and bucket controllers and blocks until the context is done.
+// If leader election is enabled, the controllers are only started once the lease is acquired and
+// an error is returned if the lease is lost.
+func (p *Provisioner) RunWithContext(ctx context.Context) (err error) {
 	defer klog.Flush()
-	log.Info("starting provisioner", "name", p.Name)
+	if p.eventBroadcaster != nil {
+		defer p.eventBroadcaster.Shutdown()
+	}
+	p.log.Info("starting provisioner", "name", p.Name)
 
-	p.informerFactory.Start(stopCh)
+	// metrics are served by every replica, regardless of leadership
+	if p.metricsAddress != "" {
+		go serveMetrics(ctx, p.log, p.metricsAddress, p.metrics.registry)
+	}
+	// so is the webhook, since the API server calls any replica
+	if p.webhook != nil {
+		go serveWebhook(ctx, p.log, p.webhook, p.claimValidator)
+	}
 
-	go func() {
-		err = p.claimController.Start(stopCh)
-	}()
+	if p.leaderElection != nil {
+		err = runWithLeaderElection(ctx, p.log, p.clientset, p.leaderElection, p.runControllers)
+	} else {
+		err = p.runControllers(ctx)
+	}
+	p.log.Info("stopping provisioner", "name", p.Name, "reason", ctx.Err())
+	return err
+}
 
-	select {
-	case <-stopCh:
-		return err
-	case <-context.Done():
-		close(stopCh)
-		log.Info("stopping provisioner", "name", p.Name, "reason", context.Err())
-		return nil
+// runControllers starts the informers and the claim controller and blocks until ctx is done.
+func (p *Provisioner) runControllers(ctx context.Context) error {
+	for _, factory := range p.informerFactories {
+		factory.Start(ctx.Done())
+	}
+	for _, factory := range p.kubeInformerFactories {
+		factory.Start(ctx.Done())
 	}
+	if p.namespaceWatcher != nil {
+		p.namespaceWatcher.start(ctx)
+	}
+	return p.claimController.Start(ctx.Done())
 }
 
 // setupInformerFactory generates an informer factory scoped to the given namespace if provided or
@@ -162,3 +295,17 @@ func setupInformerFactory(c versioned.Interface, resyncPeriod time.Duration, ns
 	}
 	return informers.NewSharedInformerFactory(c, resyncPeriod)
 }
+
+// setupKubeInformerFactory generates an informer factory scoped to the given namespace, or to the
+// cluster if empty, which only watches objects matching the selector of the provisioners' label.
+func setupKubeInformerFactory(c kubernetes.Interface, resyncPeriod time.Duration, ns string, objectSelector labels.Selector) kubeinformers.SharedInformerFactory {
+	selector := objectSelector.String()
+	return kubeinformers.NewSharedInformerFactoryWithOptions(
+		c,
+		resyncPeriod,
+		kubeinformers.WithNamespace(ns),
+		kubeinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = selector
+		}),
+	)
+}
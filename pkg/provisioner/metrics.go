@@ -0,0 +1,181 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "lib_bucket_provisioner"
+	metricsPath      = "/metrics"
+
+	// syncHandler outcomes
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+	outcomeFailed  = "failed"
+	outcomeSkipped = "skipped"
+
+	// provisioner operations
+	operationProvision = "provision"
+	operationGrant     = "grant"
+	operationUpdate    = "update"
+	operationRefresh   = "refresh"
+	operationRotate    = "rotate"
+	// revoking the previous credentials after a rotation, as opposed to revoking access to a bucket
+	operationRevokeCredentials = "revoke_credentials"
+	operationDelete            = "delete"
+	operationRevoke            = "revoke"
+	// carrying out the library defined reclaim policies
+	operationErase   = "erase"
+	operationSuspend = "suspend"
+	operationArchive = "archive"
+)
+
+// metrics holds the collectors of a single controller. Each controller has its own registry so
+// that several provisioners can run in one process without their collectors clashing.
+type metrics struct {
+	registry     *prometheus.Registry
+	syncTotal    *prometheus.CounterVec
+	callDuration *prometheus.HistogramVec
+	callErrors   *prometheus.CounterVec
+	queueRetries prometheus.Counter
+	queueDepth   prometheus.GaugeFunc
+	claims       *claimCollector
+}
+
+func newMetrics(c *obcController) *metrics {
+	constLabels := prometheus.Labels{"provisioner": c.provisionerName}
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		syncTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "reconcile_total",
+			Help:        "Number of claim reconciliations by outcome.",
+			ConstLabels: constLabels,
+		}, []string{"outcome"}),
+		callDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Name:        "provisioner_call_duration_seconds",
+			Help:        "Latency of calls to the provisioner by operation.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.05, 2, 12),
+		}, []string{"operation"}),
+		callErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "provisioner_call_errors_total",
+			Help:        "Number of calls to the provisioner which returned an error by operation.",
+			ConstLabels: constLabels,
+		}, []string{"operation"}),
+		queueRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "workqueue_retries_total",
+			Help:        "Number of claims re-queued after a failed reconciliation.",
+			ConstLabels: constLabels,
+		}),
+		queueDepth: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "workqueue_depth",
+			Help:        "Number of claims waiting to be reconciled.",
+			ConstLabels: constLabels,
+		}, func() float64 {
+			return float64(c.queue.Len())
+		}),
+		claims: &claimCollector{
+			controller: c,
+			desc: prometheus.NewDesc(
+				prometheus.BuildFQName(metricsNamespace, "", "claims"),
+				"Number of claims handled by the provisioner by phase and storage class.",
+				[]string{"phase", "storage_class"},
+				constLabels),
+		},
+	}
+	m.registry.MustRegister(
+		m.syncTotal,
+		m.callDuration,
+		m.callErrors,
+		m.queueRetries,
+		m.queueDepth,
+		m.claims)
+	return m
+}
+
+// observeCall records the latency and result of a call to the provisioner which started at start.
+func (m *metrics) observeCall(operation string, start time.Time, err error) {
+	m.callDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.callErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// claimCollector counts the claims labeled as belonging to the provisioners at scrape time, which
+// saves keeping a gauge in sync with every phase transition.
+type claimCollector struct {
+	controller *obcController
+	desc       *prometheus.Desc
+}
+
+var _ prometheus.Collector = &claimCollector{}
+
+func (cc *claimCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.desc
+}
+
+func (cc *claimCollector) Collect(ch chan<- prometheus.Metric) {
+	type key struct{ phase, class string }
+	counts := make(map[key]int)
+	for _, informer := range cc.controller.claimInformers() {
+		obcs, err := informer.Lister().List(cc.controller.objectSelector)
+		if err != nil {
+			cc.controller.log.Error(err, "error listing claims for metrics")
+			return
+		}
+		for _, obc := range obcs {
+			counts[key{string(obc.Status.Phase), obc.Spec.StorageClassName}]++
+		}
+	}
+	for k, n := range counts {
+		ch <- prometheus.MustNewConstMetric(cc.desc, prometheus.GaugeValue, float64(n), k.phase, k.class)
+	}
+}
+
+// serveMetrics serves the registry's metrics on addr until ctx is done.
+func serveMetrics(ctx context.Context, log logr.Logger, addr string, registry *prometheus.Registry) {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error(err, "error shutting down metrics server")
+		}
+	}()
+
+	log.Info("serving metrics", "address", addr, "path", metricsPath)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Error(err, "metrics server failed")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+func Test_metrics_syncHandler(t *testing.T) {
+	key := testNamespace + "/" + testName
+
+	tests := []struct {
+		name        string
+		provisioner *fakeProvisioner
+		wantOutcome string
+		wantErrors  float64
+	}{
+		{
+			name:        "successful provisioning",
+			provisioner: &fakeProvisioner{},
+			wantOutcome: outcomeSuccess,
+		},
+		{
+			name:        "provisioner error",
+			provisioner: &fakeProvisioner{err: fmt.Errorf("backend unavailable")},
+			wantOutcome: outcomeError,
+			wantErrors:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestController(tt.provisioner, []runtime.Object{newTestStorageClass()}, []runtime.Object{newTestClaim()})
+
+			_ = c.syncHandler(context.TODO(), key)
+
+			if got := testutil.ToFloat64(c.metrics.syncTotal.WithLabelValues(tt.wantOutcome)); got != 1 {
+				t.Errorf("reconcile_total{outcome=%q} = %v, want 1", tt.wantOutcome, got)
+			}
+			if got := testutil.ToFloat64(c.metrics.callErrors.WithLabelValues(operationProvision)); got != tt.wantErrors {
+				t.Errorf("provisioner_call_errors_total{operation=%q} = %v, want %v", operationProvision, got, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func Test_claimCollector(t *testing.T) {
+	c, _ := newTestController(&fakeProvisioner{}, nil, nil)
+	indexer := c.obcInformers[0].Informer().GetIndexer()
+	for i, phase := range []v1alpha1.ObjectBucketClaimStatusPhase{
+		v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		v1alpha1.ObjectBucketClaimStatusPhasePending,
+	} {
+		obc := newTestClaim()
+		obc.Name = fmt.Sprintf("%s-%d", testName, i)
+		obc.Status.Phase = phase
+		addLabels(context.TODO(), obc, c.labelsFor(provisionerName))
+		if err := indexer.Add(obc); err != nil {
+			t.Fatalf("error adding claim to indexer: %v", err)
+		}
+	}
+	// claims of other provisioners are not counted
+	other := newTestClaim()
+	other.Name = "other"
+	if err := indexer.Add(other); err != nil {
+		t.Fatalf("error adding claim to indexer: %v", err)
+	}
+
+	want := fmt.Sprintf(`
+# HELP lib_bucket_provisioner_claims Number of claims handled by the provisioner by phase and storage class.
+# TYPE lib_bucket_provisioner_claims gauge
+lib_bucket_provisioner_claims{phase="Bound",provisioner=%[1]q,storage_class=%[2]q} 2
+lib_bucket_provisioner_claims{phase="Pending",provisioner=%[1]q,storage_class=%[2]q} 1
+`, provisionerName, className)
+	if err := testutil.CollectAndCompare(c.metrics.claims, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned"
+	informers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/informers/externalversions/objectbucket.io/v1alpha1"
+)
+
+// namespaceWatcher runs the claim, Secret and ConfigMap informers of the namespaces matching a
+// label selector. The informers of a namespace are started when it is created or labeled, and
+// stopped when it is deleted or unlabeled. Namespaces being deleted are watched until they are
+// gone so that the claims in them are cleaned up. Likewise, the informers of an unlabeled
+// namespace are kept running until none of its claims holds the library's finalizer.
+type namespaceWatcher struct {
+	selector     labels.Selector
+	clientset    kubernetes.Interface
+	libClientset versioned.Interface
+	resyncPeriod time.Duration
+	controller   *obcController
+	log          logr.Logger
+	// namespaceFactory watches the namespaces matching the selector
+	namespaceFactory kubeinformers.SharedInformerFactory
+
+	mu sync.Mutex
+	// ctx is the parent of the contexts of the namespaces' informers, set by start
+	ctx     context.Context
+	watched map[string]*namespaceInformers
+}
+
+// namespaceInformers are the informers of a watched namespace.
+type namespaceInformers struct {
+	claims informers.ObjectBucketClaimInformer
+	cancel context.CancelFunc
+	// draining is set when the namespace stops being watched while claims in it still hold the
+	// library's finalizer, so that they are released once deleted
+	draining bool
+}
+
+func newNamespaceWatcher(clientset kubernetes.Interface, libClientset versioned.Interface, selector labels.Selector, resyncPeriod time.Duration, controller *obcController, log logr.Logger) *namespaceWatcher {
+	w := &namespaceWatcher{
+		selector:     selector,
+		clientset:    clientset,
+		libClientset: libClientset,
+		resyncPeriod: resyncPeriod,
+		controller:   controller,
+		log:          log,
+		namespaceFactory: kubeinformers.NewSharedInformerFactoryWithOptions(
+			clientset,
+			resyncPeriod,
+			kubeinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = selector.String()
+			}),
+		),
+		watched: make(map[string]*namespaceInformers),
+	}
+	controller.servesNamespace = w.serves
+
+	// The API server reports a namespace which stops matching the selector as deleted, updates are
+	// checked against the selector all the same.
+	w.namespaceFactory.Core().V1().Namespaces().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.watch(obj.(*corev1.Namespace).Name)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			ns := new.(*corev1.Namespace)
+			if w.selector.Matches(labels.Set(ns.Labels)) {
+				w.watch(ns.Name)
+			} else {
+				w.unwatch(ns.Name)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			name, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+			w.unwatch(name)
+		},
+	})
+	return w
+}
+
+// start starts watching namespaces. The informers of all namespaces are stopped when ctx is done.
+func (w *namespaceWatcher) start(ctx context.Context) {
+	w.mu.Lock()
+	w.ctx = ctx
+	w.mu.Unlock()
+	w.namespaceFactory.Start(ctx.Done())
+}
+
+// serves returns true if the claims of the namespace are watched.
+func (w *namespaceWatcher) serves(namespace string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ni, ok := w.watched[namespace]
+	return ok && !ni.draining
+}
+
+// watch starts the informers of the namespace, unless they are running already.
+func (w *namespaceWatcher) watch(namespace string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ni, ok := w.watched[namespace]; ok {
+		if ni.draining {
+			ni.draining = false
+			w.log.Info("watching claims of namespace again", "namespace", namespace)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(w.ctx)
+	factory := setupInformerFactory(w.libClientset, w.resyncPeriod, namespace)
+	kubeFactory := setupKubeInformerFactory(w.clientset, w.resyncPeriod, namespace, w.controller.objectSelector)
+	claims := factory.Objectbucket().V1alpha1().ObjectBucketClaims()
+	w.controller.registerClaimInformer(claims)
+	w.controller.registerOwnedResourceInformers(kubeFactory.Core().V1().Secrets(), kubeFactory.Core().V1().ConfigMaps())
+	claims.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, new interface{}) { w.stopIfReleased(namespace) },
+		DeleteFunc: func(obj interface{}) { w.stopIfReleased(namespace) },
+	})
+	factory.Start(ctx.Done())
+	kubeFactory.Start(ctx.Done())
+
+	w.watched[namespace] = &namespaceInformers{claims: claims, cancel: cancel}
+	w.log.Info("watching claims of namespace", "namespace", namespace)
+}
+
+// unwatch stops the informers of the namespace, if they are running. If claims in the namespace
+// still hold the library's finalizer, the informers are kept running until the claims are released.
+func (w *namespaceWatcher) unwatch(namespace string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ni, ok := w.watched[namespace]
+	if !ok || ni.draining {
+		return
+	}
+	if hasFinalizedClaims(ni.claims) {
+		ni.draining = true
+		w.log.Info("namespace is no longer watched, watching its claims until they are released", "namespace", namespace)
+		return
+	}
+	w.stop(namespace, ni)
+}
+
+// stopIfReleased stops the informers of a namespace which is no longer watched once none of its
+// claims holds the library's finalizer.
+func (w *namespaceWatcher) stopIfReleased(namespace string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ni, ok := w.watched[namespace]
+	if !ok || !ni.draining || hasFinalizedClaims(ni.claims) {
+		return
+	}
+	w.stop(namespace, ni)
+}
+
+// stop stops the informers of the namespace. The caller must hold w.mu.
+func (w *namespaceWatcher) stop(namespace string, ni *namespaceInformers) {
+	ni.cancel()
+	w.controller.removeClaimInformer(ni.claims)
+	delete(w.watched, namespace)
+	w.log.Info("stopped watching claims of namespace", "namespace", namespace)
+}
+
+// hasFinalizedClaims returns true if any claim listed by the informer holds the library's finalizer.
+func hasFinalizedClaims(claims informers.ObjectBucketClaimInformer) bool {
+	list, err := claims.Lister().List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return true
+	}
+	for _, obc := range list {
+		for _, f := range obc.Finalizers {
+			if f == finalizer {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	externalFake "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned/fake"
+	informers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/informers/externalversions"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+)
+
+func Test_namespaceWatcher(t *testing.T) {
+	key := testNamespace + "/" + testName
+	enabled := map[string]string{"bucket-provisioning": "enabled"}
+	client := fake.NewSimpleClientset(
+		newTestStorageClass(),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace, Labels: enabled}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other-namespace"}},
+	)
+	libClient := externalFake.NewSimpleClientset(newTestClaim())
+	factory := informers.NewSharedInformerFactory(libClient, 0)
+	c := newController(map[string]api.ProvisionerV2{provisionerName: api.AdaptProvisioner(&fakeProvisioner{})}, client, libClient, nil, factory.Objectbucket().V1alpha1().ObjectBuckets(), newOptions())
+	w := newNamespaceWatcher(client, libClient, labels.SelectorFromSet(enabled), 0, c, logr.Discard())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.start(ctx)
+
+	// the claim of the labeled namespace is queued once its informer lists it
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return c.queue.Len() == 1, nil
+	}); err != nil {
+		t.Fatalf("claim of labeled namespace was not queued: %v", err)
+	}
+	item, _ := c.queue.Get()
+	if item != key {
+		t.Errorf("queued %v, want %q", item, key)
+	}
+	c.queue.Done(item)
+	if !w.serves(testNamespace) || w.serves("other-namespace") {
+		t.Errorf("serves(%q) = %v, serves(%q) = %v, want true and false", testNamespace, w.serves(testNamespace), "other-namespace", w.serves("other-namespace"))
+	}
+	if n := len(c.claimInformers()); n != 1 {
+		t.Errorf("controller has %d claim informers, want 1", n)
+	}
+
+	// labeling the other namespace starts watching it, unlabeling the first stops watching it
+	for name, nsLabels := range map[string]map[string]string{"other-namespace": enabled, testNamespace: nil} {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nsLabels}}
+		if _, err := client.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("error updating namespace: %v", err)
+		}
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return w.serves("other-namespace") && !w.serves(testNamespace), nil
+	}); err != nil {
+		t.Fatalf("namespace label changes were not observed: %v", err)
+	}
+	if n := len(c.claimInformers()); n != 1 {
+		t.Errorf("controller has %d claim informers, want 1", n)
+	}
+
+	// claims of namespaces which are no longer watched are left alone
+	if err := c.syncHandler(ctx, key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	obc, err := claimForKey(ctx, key, libClient)
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if len(obc.Finalizers) != 0 || obc.Spec.BucketName != "" {
+		t.Errorf("claim of unwatched namespace was provisioned: %+v", obc)
+	}
+}
+
+func Test_namespaceWatcher_unlabeledNamespaceReleasesClaims(t *testing.T) {
+	key := testNamespace + "/" + testName
+	enabled := map[string]string{"bucket-provisioning": "enabled"}
+	client := fake.NewSimpleClientset(
+		newTestStorageClass(),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace, Labels: enabled}},
+	)
+	libClient := externalFake.NewSimpleClientset(newTestClaim())
+	factory := informers.NewSharedInformerFactory(libClient, 0)
+	p := &reclaimingProvisioner{}
+	o := newOptions(WithEventRecorder(record.NewFakeRecorder(100)))
+	c := newController(map[string]api.ProvisionerV2{provisionerName: api.AdaptProvisioner(p)}, client, libClient, nil, factory.Objectbucket().V1alpha1().ObjectBuckets(), o)
+	w := newNamespaceWatcher(client, libClient, labels.SelectorFromSet(enabled), 0, c, logr.Discard())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w.start(ctx)
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return w.serves(testNamespace), nil
+	}); err != nil {
+		t.Fatalf("labeled namespace is not watched: %v", err)
+	}
+	if err := c.syncHandler(ctx, key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	// the informer must have seen the claim's finalizer before the namespace is unlabeled
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return hasFinalizedClaims(c.claimInformers()[0]), nil
+	}); err != nil {
+		t.Fatalf("claim finalizer was not observed: %v", err)
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}}
+	if _, err := client.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating namespace: %v", err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return !w.serves(testNamespace), nil
+	}); err != nil {
+		t.Fatalf("unlabeled namespace is still served: %v", err)
+	}
+	// the informer of the bound claim keeps running
+	if n := len(c.claimInformers()); n != 1 {
+		t.Errorf("controller has %d claim informers, want 1", n)
+	}
+
+	// the claim is still cleaned up when it is deleted
+	obc, err := claimForKey(ctx, key, libClient)
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	now := metav1.Now()
+	obc.DeletionTimestamp = &now
+	if _, err = libClient.ObjectbucketV1alpha1().ObjectBucketClaims(testNamespace).Update(ctx, obc, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating claim: %v", err)
+	}
+	if err = c.syncHandler(ctx, key); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	if strings.Join(p.calls, ",") != operationDelete {
+		t.Errorf("provisioner calls = %v, want [%s]", p.calls, operationDelete)
+	}
+	if obc, err = claimForKey(ctx, key, libClient); err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if len(obc.Finalizers) != 0 {
+		t.Errorf("claim was not released, finalizers = %v", obc.Finalizers)
+	}
+
+	// and the namespace's informers are stopped once it is released
+	if err = wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return len(c.claimInformers()) == 0, nil
+	}); err != nil {
+		t.Errorf("informers of the unlabeled namespace were not stopped: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2/klogr"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+)
+
+// threadsEnvVar may be set to the number of workers when WithWorkers is not given. It is kept for
+// backwards compatibility, new code should use WithWorkers.
+const threadsEnvVar = "LIB_BUCKET_PROVISIONER_THREADS"
+
+// defaultRefreshWindow is how long before their expiry temporary credentials are refreshed.
+const defaultRefreshWindow = 5 * time.Minute
+
+// Option configures optional behavior of the Provisioner returned by NewProvisioner.
+type Option func(*options)
+
+// options holds the configuration gathered from the Options passed to NewProvisioner.
+type options struct {
+	leaderElection    *LeaderElectionConfig
+	metricsAddress    string
+	webhook           *WebhookConfig
+	workers           int
+	resyncPeriod      time.Duration
+	rateLimiter       workqueue.RateLimiter
+	namespaces        []string
+	namespaceSelector string
+	logger            logr.Logger
+	recorder          record.EventRecorder
+	callTimeout       time.Duration
+	refreshWindow     time.Duration
+	nameRules         api.BucketNameRules
+}
+
+// newOptions applies the Options over the defaults.
+func newOptions(opts ...Option) *options {
+	o := &options{
+		workers:       1,
+		rateLimiter:   workqueue.DefaultControllerRateLimiter(),
+		logger:        klogr.New().WithName(api.Domain + "/provisioner-manager"),
+		refreshWindow: defaultRefreshWindow,
+		nameRules:     api.S3BucketNameRules{},
+	}
+	threads, set := os.LookupEnv(threadsEnvVar)
+	n, err := strconv.Atoi(threads)
+	valid := err == nil && n >= 1
+	if set && valid {
+		o.workers = n
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if set && !valid {
+		o.logger.Info("ignoring invalid worker count, it must be a number of at least 1", "env", threadsEnvVar, "value", threads)
+	}
+	return o
+}
+
+// WithLeaderElection enables leader election between replicas of the provisioner. Only the
+// replica holding the lease runs the OBC controller. Unset fields of cfg are defaulted.
+func WithLeaderElection(cfg LeaderElectionConfig) Option {
+	return func(o *options) {
+		o.leaderElection = &cfg
+	}
+}
+
+// WithMetricsAddress serves Prometheus metrics on the given address, e.g. ":8080", under the
+// /metrics path. Metrics are not served unless this option is given.
+func WithMetricsAddress(addr string) Option {
+	return func(o *options) {
+		o.metricsAddress = addr
+	}
+}
+
+// WithWebhook serves a validating admission webhook for OBCs, which rejects invalid claims at
+// admission time. A ValidatingWebhookConfiguration routing OBC creates and updates of this
+// provisioner's namespaces to the webhook must be deployed alongside. The webhook is not served
+// unless this option is given.
+func WithWebhook(cfg WebhookConfig) Option {
+	return func(o *options) {
+		o.webhook = &cfg
+	}
+}
+
+// WithWorkers sets the number of OBCs reconciled concurrently, which must be at least 1. Defaults
+// to 1.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// WithResyncPeriod sets how often the informers re-deliver every OBC to the controller. Defaults
+// to 0, which disables periodic resyncs.
+func WithResyncPeriod(d time.Duration) Option {
+	return func(o *options) {
+		o.resyncPeriod = d
+	}
+}
+
+// Defaults of the RateLimiterConfig fields, matching client-go's default controller rate limiter.
+const (
+	defaultRateLimiterBaseDelay = 5 * time.Millisecond
+	defaultRateLimiterMaxDelay  = 1000 * time.Second
+	defaultRateLimiterQPS       = 10
+	defaultRateLimiterBurst     = 100
+)
+
+// RateLimiterConfig configures how quickly failed OBCs are retried. A retry is delayed by the
+// larger of a per-OBC exponential backoff and an overall token bucket. Fields which are not set,
+// or not positive, are defaulted.
+type RateLimiterConfig struct {
+	// BaseDelay is the delay of the first retry of an OBC. It doubles with every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the per-OBC delay.
+	MaxDelay time.Duration
+	// QPS is the overall number of retries allowed per second.
+	QPS float64
+	// Burst is the overall number of retries allowed at once.
+	Burst int
+}
+
+// WithRateLimiter sets the rate limiter of the OBC work queue. Defaults to client-go's default
+// controller rate limiter: a 5ms base delay, a 1000s max delay, 10 QPS and a burst of 100.
+func WithRateLimiter(cfg RateLimiterConfig) Option {
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultRateLimiterBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultRateLimiterMaxDelay
+	}
+	if cfg.QPS <= 0 {
+		cfg.QPS = defaultRateLimiterQPS
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = defaultRateLimiterBurst
+	}
+	return func(o *options) {
+		o.rateLimiter = workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(cfg.BaseDelay, cfg.MaxDelay),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(cfg.QPS), cfg.Burst)},
+		)
+	}
+}
+
+// WithNamespaces restricts the provisioner to OBCs in the given namespaces, in addition to the
+// namespace passed to NewProvisioner if it is not empty.
+func WithNamespaces(namespaces ...string) Option {
+	return func(o *options) {
+		o.namespaces = append(o.namespaces, namespaces...)
+	}
+}
+
+// WithNamespaceSelector restricts the provisioner to OBCs in the namespaces matching the label
+// selector, e.g. "bucket-provisioning=enabled". The claims of a namespace are watched from the time
+// it is labeled and are no longer provisioned once it is unlabeled, which lets cluster administrators
+// control the namespaces in which tenants may claim buckets. It may not be combined with a namespace
+// given to NewProvisioner or WithNamespaces. Bound and deleted claims of an unlabeled namespace are
+// still reconciled, so that their buckets are cleaned up.
+func WithNamespaceSelector(selector string) Option {
+	return func(o *options) {
+		o.namespaceSelector = selector
+	}
+}
+
+// WithLogger sets the logger used by the provisioner. Defaults to a klog backed logger.
+func WithLogger(logger logr.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithEventRecorder sets the recorder used to record events on OBCs and OBs. By default, a
+// recorder writing to the API server is created.
+func WithEventRecorder(recorder record.EventRecorder) Option {
+	return func(o *options) {
+		o.recorder = recorder
+	}
+}
+
+// WithCallTimeout bounds every call to the provisioner by the given timeout. A call which times
+// out fails like any other provisioner error and the claim is retried. Defaults to 0, which leaves
+// calls unbounded until the provisioner is shut down.
+func WithCallTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.callTimeout = d
+	}
+}
+
+// WithRefreshWindow sets how long before their expiry temporary credentials are refreshed. Credentials
+// whose lifetime is shorter than twice the window are refreshed halfway through their lifetime.
+// Defaults to 5 minutes.
+func WithRefreshWindow(d time.Duration) Option {
+	return func(o *options) {
+		o.refreshWindow = d
+	}
+}
+
+// WithBucketNameRules sets the rules by which bucket names are generated and validated, e.g. for
+// object stores other than S3. Defaults to api.S3BucketNameRules.
+func WithBucketNameRules(rules api.BucketNameRules) Option {
+	return func(o *options) {
+		o.nameRules = rules
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+)
+
+func Test_newOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         string
+		opts        []Option
+		wantWorkers int
+	}{
+		{
+			name:        "default",
+			wantWorkers: 1,
+		},
+		{
+			name:        "threads env var",
+			env:         "4",
+			wantWorkers: 4,
+		},
+		{
+			name:        "zero threads env var",
+			env:         "0",
+			wantWorkers: 1,
+		},
+		{
+			name:        "negative threads env var",
+			env:         "-2",
+			wantWorkers: 1,
+		},
+		{
+			name:        "invalid threads env var",
+			env:         "four",
+			wantWorkers: 1,
+		},
+		{
+			name:        "option overrides env var",
+			env:         "4",
+			opts:        []Option{WithWorkers(8)},
+			wantWorkers: 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv(threadsEnvVar, tt.env)
+			}
+			o := newOptions(tt.opts...)
+			if o.workers != tt.wantWorkers {
+				t.Errorf("newOptions() workers = %d, want %d", o.workers, tt.wantWorkers)
+			}
+			if o.rateLimiter == nil {
+				t.Error("newOptions() did not default the rate limiter")
+			}
+		})
+	}
+}
+
+func TestNewMultiProvisioner_invalidWorkers(t *testing.T) {
+	provisioners := map[string]api.ProvisionerV2{provisionerName: api.AdaptProvisioner(&fakeProvisioner{})}
+	for _, n := range []int{0, -1} {
+		if _, err := NewMultiProvisioner(&rest.Config{}, provisioners, testNamespace, WithWorkers(n)); err == nil {
+			t.Errorf("NewMultiProvisioner() with %d workers did not fail", n)
+		}
+	}
+}
+
+func TestWithRateLimiter(t *testing.T) {
+	o := newOptions(WithRateLimiter(RateLimiterConfig{
+		BaseDelay: time.Second,
+		MaxDelay:  time.Minute,
+		QPS:       100,
+		Burst:     100,
+	}))
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if got := o.rateLimiter.When("key"); got != want {
+			t.Errorf("retry %d delay = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestWithRateLimiter_defaults(t *testing.T) {
+	o := newOptions(WithRateLimiter(RateLimiterConfig{}))
+	for i, want := range []time.Duration{defaultRateLimiterBaseDelay, 2 * defaultRateLimiterBaseDelay} {
+		if got := o.rateLimiter.When("key"); got != want {
+			t.Errorf("retry %d delay = %v, want %v", i, got, want)
+		}
+	}
+	// the token bucket lets a burst of other keys through without delay
+	for i := 0; i < defaultRateLimiterBurst-2; i++ {
+		if got := o.rateLimiter.When(fmt.Sprintf("key-%d", i)); got != defaultRateLimiterBaseDelay {
+			t.Fatalf("delay of key %d = %v, want %v", i, got, defaultRateLimiterBaseDelay)
+		}
+	}
+	// and the per-key delay is capped
+	for i := 0; i < 30; i++ {
+		o.rateLimiter.When("capped")
+	}
+	if got := o.rateLimiter.When("capped"); got != defaultRateLimiterMaxDelay {
+		t.Errorf("capped delay = %v, want %v", got, defaultRateLimiterMaxDelay)
+	}
+}
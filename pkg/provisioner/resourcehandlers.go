@@ -22,15 +22,22 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/go-logr/logr"
+
 	"k8s.io/client-go/kubernetes"
 
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/connection"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+	liberrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
 )
 
 const (
@@ -40,28 +47,98 @@ const (
 	// attempt
 	defaultRetryTimeout = time.Second * 30
 
-	bucketName      = "BUCKET_NAME"
-	bucketHost      = "BUCKET_HOST"
-	bucketPort      = "BUCKET_PORT"
-	bucketRegion    = "BUCKET_REGION"
-	bucketSubRegion = "BUCKET_SUBREGION"
+	bucketName            = "BUCKET_NAME"
+	bucketHost            = "BUCKET_HOST"
+	bucketPort            = "BUCKET_PORT"
+	bucketRegion          = "BUCKET_REGION"
+	bucketSubRegion       = "BUCKET_SUBREGION"
+	bucketURL             = "BUCKET_URL"
+	bucketScheme          = "BUCKET_SCHEME"
+	bucketCA              = "BUCKET_CA"
+	bucketInsecure        = "BUCKET_TLS_INSECURE"
+	bucketAddressingStyle = "BUCKET_ADDRESSING_STYLE"
 	// finalizer is applied to all resources generated by the provisioner and to the obc
 	finalizer = api.Domain + "/finalizer"
 	// label applied to all resources generated by the provisioner and to the obc
-	provisionerLabelKey    = "bucket-provisioner"
-	objectBucketNameFormat = "obc-%s-%s"
+	provisionerLabelKey = "bucket-provisioner"
+	// objectBucketNamePrefix is followed by the claim's UID in the names of OBs
+	objectBucketNamePrefix = "obc-"
+	// claimIndex indexes OBs by the namespace/name key of the claim they are bound to
+	claimIndex = "claim"
+)
+
+var (
+	// reservedConfigMapKeys are written by the library to the ConfigMap and may not be set through
+	// Endpoint.AdditionalConfigData.
+	reservedConfigMapKeys = []string{
+		bucketName, bucketHost, bucketPort, bucketRegion, bucketSubRegion,
+		bucketURL, bucketScheme, bucketCA, bucketInsecure, bucketAddressingStyle,
+	}
+	// reservedSecretKeys are written by the library to the Secret and may not be set through
+	// Authentication.AdditionalSecretData.
+	reservedSecretKeys = []string{v1alpha1.AwsKeyField, v1alpha1.AwsSecretField, v1alpha1.AwsSessionField}
 )
 
-// newBucketConfigMap returns a config map from a given endpoint and ObjectBucketClaim.
-// A finalizer is added to reduce chances of the CM being accidentally deleted. An OwnerReference
+// checkReservedKeys returns a permanent error if data sets any of the reserved keys, since the
+// provisioner will keep returning the same data when retried.
+func checkReservedKeys(data map[string]string, reserved []string, field string) error {
+	for _, key := range reserved {
+		if _, ok := data[key]; ok {
+			return liberrors.NewPermanentError(liberrors.ReasonInvalidConnection, fmt.Sprintf("%s sets reserved key %q", field, key))
+		}
+	}
+	return nil
+}
+
+// newBucketConfigMap returns a config map from a given endpoint and ObjectBucketClaim, with its
+// keys renamed by keyMapping. A finalizer is added to reduce chances of the CM being accidentally deleted. An OwnerReference
 // is added so that the CM is automatically garbage collected when the parent OBC is deleted.
-func newBucketConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint, labels map[string]string) (*corev1.ConfigMap, error) {
+func newBucketConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint, labels, keyMapping map[string]string) (*corev1.ConfigMap, error) {
 	if ep == nil {
 		return nil, fmt.Errorf("cannot construct configMap, got nil Endpoint")
 	}
 	if obc == nil {
 		return nil, fmt.Errorf("cannot construct configMap, got nil OBC")
 	}
+	if err := checkReservedKeys(ep.AdditionalConfigData, reservedConfigMapKeys, "additionalConfig"); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string, len(ep.AdditionalConfigData)+len(reservedConfigMapKeys))
+	for k, v := range ep.AdditionalConfigData {
+		data[k] = v
+	}
+	data[bucketName] = ep.BucketName
+	data[bucketHost] = ep.BucketHost
+	data[bucketPort] = strconv.Itoa(ep.BucketPort)
+	data[bucketRegion] = ep.Region
+	data[bucketSubRegion] = ep.SubRegion
+
+	u, err := connection.URL(ep)
+	if err != nil {
+		return nil, err
+	}
+	if u != nil {
+		data[bucketURL] = u.String()
+		data[bucketScheme] = u.Scheme
+	}
+	switch ep.AddressingStyle {
+	case "":
+	case v1alpha1.AddressingStylePath, v1alpha1.AddressingStyleVirtual:
+		data[bucketAddressingStyle] = string(ep.AddressingStyle)
+	default:
+		return nil, liberrors.NewPermanentError(liberrors.ReasonInvalidConnection, fmt.Sprintf("unsupported addressing style %q", ep.AddressingStyle))
+	}
+	if ep.TLS != nil {
+		data[bucketInsecure] = strconv.FormatBool(ep.TLS.InsecureSkipVerify)
+		if ep.TLS.CABundle != "" {
+			data[bucketCA] = ep.TLS.CABundle
+		}
+	}
+	data, err = remapKeys(data, keyMapping)
+	if err != nil {
+		return nil, err
+	}
 
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -73,28 +150,45 @@ func newBucketConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint,
 				makeOwnerReference(obc),
 			},
 		},
-		Data: map[string]string{
-			bucketName:      ep.BucketName,
-			bucketHost:      ep.BucketHost,
-			bucketPort:      strconv.Itoa(ep.BucketPort),
-			bucketRegion:    ep.Region,
-			bucketSubRegion: ep.SubRegion,
-		},
+		Data: data,
 	}, nil
 }
 
+// resolveCABundle returns a copy of the endpoint with CABundle set from the ConfigMap referenced by CABundleRef. The
+// endpoint is returned unchanged if it does not reference a CA bundle or already has one inline.
+func resolveCABundle(ctx context.Context, ep *v1alpha1.Endpoint, c kubernetes.Interface) (*v1alpha1.Endpoint, error) {
+	if ep == nil || ep.TLS == nil || ep.TLS.CABundle != "" || ep.TLS.CABundleRef == nil {
+		return ep, nil
+	}
+	ref := ep.TLS.CABundleRef
+	cm, err := c.CoreV1().ConfigMaps(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CA bundle configmap %q: %w", ref.Namespace+"/"+ref.Name, err)
+	}
+	bundle, ok := cm.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("CA bundle configmap %q has no key %q", ref.Namespace+"/"+ref.Name, ref.Key)
+	}
+	ep = ep.DeepCopy()
+	ep.TLS.CABundle = bundle
+	return ep, nil
+}
+
 // newCredentialsSecret returns a secret with data appropriate to the supported authenticaion
-// method. Even if the values for the Authentication keys are empty, we generate the secret.
+// method and the rendered connection files, with its keys renamed by keyMapping. Even if the values for the Authentication keys are empty, we generate the secret.
 // A finalizer is added to reduce chances of the secret being accidentally deleted.
 // An OwnerReference is added so that the secret is automatically garbage collected when the
 // parent OBC is deleted.
-func newCredentialsSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authentication, labels map[string]string) (*corev1.Secret, error) {
+func newCredentialsSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authentication, files, labels, keyMapping map[string]string) (*corev1.Secret, error) {
 	if obc == nil {
 		return nil, fmt.Errorf("ObjectBucketClaim required to generate secret")
 	}
 	if auth == nil {
 		return nil, fmt.Errorf("got nil authentication, nothing to do")
 	}
+	if err := checkReservedKeys(auth.AdditionalSecretData, reservedSecretKeys, "additionalSecretData"); err != nil {
+		return nil, err
+	}
 
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -108,24 +202,164 @@ func newCredentialsSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authen
 		},
 	}
 
-	secret.StringData = auth.ToMap()
+	data := auth.ToMap()
+	for name, content := range files {
+		if _, ok := data[name]; ok {
+			return nil, liberrors.NewPermanentError(liberrors.ReasonInvalidConnection, fmt.Sprintf("connection file %q conflicts with a Secret key of the same name", name))
+		}
+		data[name] = content
+	}
+	data, err := remapKeys(data, keyMapping)
+	if err != nil {
+		return nil, err
+	}
+	secret.StringData = data
 	return secret, nil
 }
 
-func createOrUpdateObjectBucket(ob *v1alpha1.ObjectBucket, c versioned.Interface) (result *v1alpha1.ObjectBucket, err error) {
-	logD.Info("creating ObjectBucket", "name", ob.Name)
+// newBindingSecret returns the claim's Service Binding for Kubernetes (servicebinding.io) binding
+// Secret, holding the well-known binding entries of the bucket. Unlike the claim's Secret, it has no
+// finalizer and is only removed through its OwnerReference when the claim is deleted.
+func newBindingSecret(obc *v1alpha1.ObjectBucketClaim, provider string, ep *v1alpha1.Endpoint, auth *v1alpha1.Authentication, labels map[string]string) (*corev1.Secret, error) {
+	if auth == nil {
+		return nil, fmt.Errorf("got nil authentication, nothing to do")
+	}
+	data, err := connection.Binding(provider, ep, auth)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      composeBindingSecretName(obc),
+			Namespace: obc.Namespace,
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				makeOwnerReference(obc),
+			},
+		},
+		Type:       corev1.SecretType("servicebinding.io/" + connection.BindingType),
+		StringData: data,
+	}, nil
+}
+
+// connectionFiles renders the connection files requested by the connectionFormats parameter of
+// the class, keyed by file name.
+func connectionFiles(class *storagev1.StorageClass, ep *v1alpha1.Endpoint, auth *v1alpha1.Authentication) (map[string]string, error) {
+	if class == nil || class.Parameters[v1alpha1.StorageClassConnectionFormats] == "" {
+		return nil, nil
+	}
+	formats, err := connection.ParseFormats(class.Parameters[v1alpha1.StorageClassConnectionFormats])
+	if err != nil {
+		return nil, err
+	}
+	return connection.RenderAll(formats, ep, auth)
+}
+
+// checkConnectionFormats returns a permanent error if the connectionFormats parameter of the
+// class is invalid, or if a connection file would be written under the same key as the credentials
+// once the claim's Secret key mapping is applied. Conflicts with the keys of additionalSecretData
+// are only known once the bucket is provisioned.
+func checkConnectionFormats(obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) error {
+	if class == nil || class.Parameters[v1alpha1.StorageClassConnectionFormats] == "" {
+		return nil
+	}
+	formats, err := connection.ParseFormats(class.Parameters[v1alpha1.StorageClassConnectionFormats])
+	if err != nil {
+		return err
+	}
+	mapping, err := secretKeyMapping(obc, class)
+	if err != nil {
+		return err
+	}
+	for _, f := range formats {
+		file := mappedKey(connection.FileName(f), mapping)
+		for _, key := range reservedSecretKeys {
+			if mappedKey(key, mapping) == file {
+				return liberrors.NewPermanentError(liberrors.ReasonInvalidParameters,
+					fmt.Sprintf("connection file of format %q conflicts with Secret key %q", f, file))
+			}
+		}
+	}
+	return nil
+}
+
+// errNotOwned returns the permanent error for an existing object which the claim would replace but
+// does not own. Such objects are not adopted, since they may belong to another application.
+func errNotOwned(kind string, obj metav1.Object, field string) error {
+	return liberrors.NewPermanentError(liberrors.ReasonNameConflict,
+		fmt.Sprintf("%s %q already exists and is not owned by the claim, delete it or recreate the claim with spec.%s set to another name", kind, obj.GetNamespace()+"/"+obj.GetName(), field))
+}
+
+// checkGeneratedObjects returns an error if the claim's Secret or ConfigMap cannot be written,
+// because their key mappings or connection formats are invalid or objects of the same name exist
+// which the claim does not own. It is called before the bucket is provisioned, so that such claims fail without
+// provisioning a bucket.
+func checkGeneratedObjects(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass, c kubernetes.Interface) error {
+	if _, err := secretKeyMapping(obc, class); err != nil {
+		return err
+	}
+	if _, err := configMapKeyMapping(obc, class); err != nil {
+		return err
+	}
+	if err := checkConnectionFormats(obc, class); err != nil {
+		return err
+	}
+
+	secretNames := []string{composeSecretName(obc)}
+	if binding, err := serviceBindingEnabled(class); err != nil {
+		return err
+	} else if binding {
+		secretNames = append(secretNames, composeBindingSecretName(obc))
+	}
+	for _, name := range secretNames {
+		secret, err := c.CoreV1().Secrets(obc.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get secret %q for obc %q: %v", obc.Namespace+"/"+name, obc.Name, err)
+		}
+		if !metav1.IsControlledBy(secret, obc) {
+			return errNotOwned("Secret", secret, "secretName")
+		}
+	}
+
+	configMap, err := configMapForClaim(ctx, obc, c)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get configmap %q for obc %q: %v", obc.Namespace+"/"+composeConfigMapName(obc), obc.Name, err)
+	}
+	if !metav1.IsControlledBy(configMap, obc) {
+		return errNotOwned("ConfigMap", configMap, "configMapName")
+	}
+	return nil
+}
+
+func createOrUpdateObjectBucket(ctx context.Context, ob *v1alpha1.ObjectBucket, c versioned.Interface) (result *v1alpha1.ObjectBucket, err error) {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("creating ObjectBucket", "name", ob.Name)
 
-	result, err = c.ObjectbucketV1alpha1().ObjectBuckets().Create(context.TODO(), ob, metav1.CreateOptions{})
+	result, err = c.ObjectbucketV1alpha1().ObjectBuckets().Create(ctx, ob, metav1.CreateOptions{})
 	if err != nil {
 		if errors.IsAlreadyExists(err) {
-			logD.Info("updating ObjectBucket", "name", ob.Name)
+			log.V(1).Info("updating ObjectBucket", "name", ob.Name)
 			var currentOB *v1alpha1.ObjectBucket
-			currentOB, err = c.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), ob.Name, metav1.GetOptions{})
+			currentOB, err = c.ObjectbucketV1alpha1().ObjectBuckets().Get(ctx, ob.Name, metav1.GetOptions{})
 			if err != nil {
 				return ob, fmt.Errorf("failed to update OB %s: failed to get current version of OB: %v", ob.Name, err)
 			}
+			if currentOB.DeletionTimestamp != nil {
+				// The OB was deleted while its claim is still bound. Release it so that it is
+				// re-created once deleted.
+				return ob, recreateAfterDeletion(ctx, currentOB, func() error {
+					_, err := c.ObjectbucketV1alpha1().ObjectBuckets().Update(ctx, currentOB, metav1.UpdateOptions{})
+					return err
+				})
+			}
 			ob.ResourceVersion = currentOB.ResourceVersion // this must be set for updates
-			result, err = c.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{})
+			result, err = c.ObjectbucketV1alpha1().ObjectBuckets().Update(ctx, ob, metav1.UpdateOptions{})
 			if err != nil {
 				// return input ob here since result is nil on error returns
 				return ob, fmt.Errorf("failed to update OB %s: %v", ob.Name, err)
@@ -139,17 +373,64 @@ func createOrUpdateObjectBucket(ob *v1alpha1.ObjectBucket, c versioned.Interface
 	return result, err
 }
 
-func createOrUpdateSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authentication, labels map[string]string, c kubernetes.Interface) error {
-	secret, err := newCredentialsSecret(obc, auth, labels)
+// createOrUpdateSecret writes the claim's Secret and, if enabled by the storage class, its binding
+// Secret. ep is only used to render connection files and the binding.
+func createOrUpdateSecret(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass, auth *v1alpha1.Authentication, ep *v1alpha1.Endpoint, labels map[string]string, c kubernetes.Interface) error {
+	keyMapping, err := secretKeyMapping(obc, class)
 	if err != nil {
 		return err
 	}
-	logD.Info("creating Secret", "name", secret.Namespace+"/"+secret.Name)
-	_, err = c.CoreV1().Secrets(obc.Namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	ep, err = resolveCABundle(ctx, ep, c)
+	if err != nil {
+		return err
+	}
+	files, err := connectionFiles(class, ep, auth)
+	if err != nil {
+		return err
+	}
+	secret, err := newCredentialsSecret(obc, auth, files, labels, keyMapping)
+	if err != nil {
+		return err
+	}
+	if err = applySecret(ctx, obc, secret, c); err != nil {
+		return err
+	}
+
+	binding, err := serviceBindingEnabled(class)
+	if err != nil || !binding {
+		return err
+	}
+	secret, err = newBindingSecret(obc, class.Provisioner, ep, auth, labels)
+	if err != nil {
+		return err
+	}
+	return applySecret(ctx, obc, secret, c)
+}
+
+// applySecret creates secret, or updates it if it already exists and is owned by the claim.
+func applySecret(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, secret *corev1.Secret, c kubernetes.Interface) error {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("creating Secret", "name", secret.Namespace+"/"+secret.Name)
+	_, err := c.CoreV1().Secrets(obc.Namespace).Create(ctx, secret, metav1.CreateOptions{})
 	if err != nil {
 		if errors.IsAlreadyExists(err) {
-			logD.Info("updating Secret", "name", secret.Namespace+"/"+secret.Name)
-			_, err = c.CoreV1().Secrets(obc.Namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+			log.V(1).Info("updating Secret", "name", secret.Namespace+"/"+secret.Name)
+			var current *corev1.Secret
+			current, err = c.CoreV1().Secrets(obc.Namespace).Get(ctx, secret.Name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get secret %q for obc %q: %v", secret.Namespace+"/"+secret.Name, obc.Name, err)
+			}
+			if !metav1.IsControlledBy(current, obc) {
+				return errNotOwned("Secret", current, "secretName")
+			}
+			if current.DeletionTimestamp != nil {
+				return recreateAfterDeletion(ctx, current, func() error {
+					_, err := c.CoreV1().Secrets(obc.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+					return err
+				})
+			}
+			secret.ResourceVersion = current.ResourceVersion
+			_, err = c.CoreV1().Secrets(obc.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
 			if err != nil {
 				return fmt.Errorf("failed to update secret %q for obc %q", secret.Namespace+"/"+secret.Name, obc.Name)
 			}
@@ -158,18 +439,42 @@ func createOrUpdateSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authen
 	return err
 }
 
-func createOrUpdateConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint, labels map[string]string, c kubernetes.Interface) error {
-	configMap, err := newBucketConfigMap(obc, ep, labels)
+func createOrUpdateConfigMap(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass, ep *v1alpha1.Endpoint, labels map[string]string, c kubernetes.Interface) error {
+	log := logr.FromContextOrDiscard(ctx)
+	keyMapping, err := configMapKeyMapping(obc, class)
+	if err != nil {
+		return err
+	}
+	ep, err = resolveCABundle(ctx, ep, c)
+	if err != nil {
+		return err
+	}
+	configMap, err := newBucketConfigMap(obc, ep, labels, keyMapping)
 	if err != nil {
 		return err
 	}
 
-	logD.Info("creating ConfigMap", "name", configMap.Namespace+"/"+configMap.Name)
-	_, err = c.CoreV1().ConfigMaps(obc.Namespace).Create(context.TODO(), configMap, metav1.CreateOptions{})
+	log.V(1).Info("creating ConfigMap", "name", configMap.Namespace+"/"+configMap.Name)
+	_, err = c.CoreV1().ConfigMaps(obc.Namespace).Create(ctx, configMap, metav1.CreateOptions{})
 	if err != nil {
 		if errors.IsAlreadyExists(err) {
-			logD.Info("updating ConfigMap", "name", configMap.Namespace+"/"+configMap.Name)
-			_, err = c.CoreV1().ConfigMaps(obc.Namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{})
+			log.V(1).Info("updating ConfigMap", "name", configMap.Namespace+"/"+configMap.Name)
+			var current *corev1.ConfigMap
+			current, err = c.CoreV1().ConfigMaps(obc.Namespace).Get(ctx, configMap.Name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get configmap %q for obc %q: %v", configMap.Namespace+"/"+configMap.Name, obc.Name, err)
+			}
+			if !metav1.IsControlledBy(current, obc) {
+				return errNotOwned("ConfigMap", current, "configMapName")
+			}
+			if current.DeletionTimestamp != nil {
+				return recreateAfterDeletion(ctx, current, func() error {
+					_, err := c.CoreV1().ConfigMaps(obc.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+					return err
+				})
+			}
+			configMap.ResourceVersion = current.ResourceVersion
+			_, err = c.CoreV1().ConfigMaps(obc.Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
 			if err != nil {
 				return fmt.Errorf("failed to update configmap %q for obc %q", configMap.Namespace+"/"+configMap.Name, obc.Name)
 			}
@@ -178,20 +483,35 @@ func createOrUpdateConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpo
 	return err
 }
 
+// recreateAfterDeletion removes the finalizer from obj, a generated object which was deleted while
+// its claim still exists, using update to persist the change. An error is always returned so that
+// the claim is re-queued and the object re-created once it is gone.
+func recreateAfterDeletion(ctx context.Context, obj metav1.Object, update func() error) error {
+	log := logr.FromContextOrDiscard(ctx)
+
+	log.Info("generated object was deleted, releasing it to be re-created", "name", obj.GetNamespace()+"/"+obj.GetName())
+	removeFinalizer(obj)
+	if err := update(); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to release deleted object %q: %v", obj.GetName(), err)
+	}
+	return fmt.Errorf("object %q is being deleted, it will be re-created", obj.GetName())
+}
+
 // Only the finalizer needs to be removed. The CM will be garbage collected since its
 // ownerReference refers to the parent OBC.
-func releaseConfigMap(cm *corev1.ConfigMap, c kubernetes.Interface) (err error) {
+func releaseConfigMap(ctx context.Context, cm *corev1.ConfigMap, c kubernetes.Interface) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
 	if cm == nil {
-		logD.Info("got nil configmap, skipping")
+		log.V(1).Info("got nil configmap, skipping")
 		return nil
 	}
-	cm, err = c.CoreV1().ConfigMaps(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+	cm, err = c.CoreV1().ConfigMaps(cm.Namespace).Get(ctx, cm.Name, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
-	logD.Info("removing configmap finalizer")
+	log.V(1).Info("removing configmap finalizer")
 	removeFinalizer(cm)
-	cm, err = c.CoreV1().ConfigMaps(cm.Namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	cm, err = c.CoreV1().ConfigMaps(cm.Namespace).Update(ctx, cm, metav1.UpdateOptions{})
 	if err != nil {
 		return err
 	}
@@ -201,18 +521,19 @@ func releaseConfigMap(cm *corev1.ConfigMap, c kubernetes.Interface) (err error)
 
 // Only the finalizer needs to be removed. The Secret will be garbage collected since its
 // ownerReference refers to the parent OBC.
-func releaseSecret(sec *corev1.Secret, c kubernetes.Interface) (err error) {
+func releaseSecret(ctx context.Context, sec *corev1.Secret, c kubernetes.Interface) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
 	if sec == nil {
-		logD.Info("got nil secret, skipping")
+		log.V(1).Info("got nil secret, skipping")
 		return nil
 	}
-	sec, err = c.CoreV1().Secrets(sec.Namespace).Get(context.TODO(), sec.Name, metav1.GetOptions{})
+	sec, err = c.CoreV1().Secrets(sec.Namespace).Get(ctx, sec.Name, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
-	logD.Info("removing secret finalizer")
+	log.V(1).Info("removing secret finalizer")
 	removeFinalizer(sec)
-	sec, err = c.CoreV1().Secrets(sec.Namespace).Update(context.TODO(), sec, metav1.UpdateOptions{})
+	sec, err = c.CoreV1().Secrets(sec.Namespace).Update(ctx, sec, metav1.UpdateOptions{})
 	if err != nil {
 		return err
 	}
@@ -220,21 +541,41 @@ func releaseSecret(sec *corev1.Secret, c kubernetes.Interface) (err error) {
 	return nil
 }
 
+// orphanSecret removes the finalizer and the owner references from the Secret of a suspended
+// bucket, so that it is not garbage collected with the claim and its credentials remain available.
+func orphanSecret(ctx context.Context, sec *corev1.Secret, c kubernetes.Interface) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
+	if sec == nil {
+		log.V(1).Info("got nil secret, skipping")
+		return nil
+	}
+	sec, err = c.CoreV1().Secrets(sec.Namespace).Get(ctx, sec.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	log.V(1).Info("removing secret finalizer and owner references")
+	removeFinalizer(sec)
+	sec.OwnerReferences = nil
+	_, err = c.CoreV1().Secrets(sec.Namespace).Update(ctx, sec, metav1.UpdateOptions{})
+	return err
+}
+
 // Remove the finalizer allowing the OBC to finally be deleted.
-func releaseOBC(obc *v1alpha1.ObjectBucketClaim, c versioned.Interface) (err error) {
+func releaseOBC(ctx context.Context, obc *v1alpha1.ObjectBucketClaim, c versioned.Interface) (err error) {
+	log := logr.FromContextOrDiscard(ctx)
 	if obc == nil {
-		logD.Info("got nil obc, skipping")
+		log.V(1).Info("got nil obc, skipping")
 		return nil
 	}
 	obcNsName := obc.Namespace + "/" + obc.Name
-	obc, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	obc, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(ctx, obc.Name, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("unable to Get obc %q in order to remove finalizer: %v", obcNsName, err)
 	}
-	logD.Info("removing obc finalizer")
+	log.V(1).Info("removing obc finalizer")
 	removeFinalizer(obc)
 
-	obc, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(context.TODO(), obc, metav1.UpdateOptions{})
+	obc, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(ctx, obc, metav1.UpdateOptions{})
 	if err != nil {
 		return fmt.Errorf("unable to Update obc %q to reflect removed finalizer: %v", obcNsName, err)
 	}
@@ -242,26 +583,42 @@ func releaseOBC(obc *v1alpha1.ObjectBucketClaim, c versioned.Interface) (err err
 	return nil
 }
 
+// releaseObjectBucket removes the finalizer from the OB of a suspended bucket, which is kept, in
+// the Released phase, so that the bucket remains recorded after its claim is deleted.
+func releaseObjectBucket(ctx context.Context, ob *v1alpha1.ObjectBucket, c versioned.Interface) error {
+	log := logr.FromContextOrDiscard(ctx)
+	if ob == nil {
+		log.V(1).Info("got nil objectBucket, skipping")
+		return nil
+	}
+
+	log.V(1).Info("removing ObjectBucket finalizer", "name", ob.Name)
+	removeFinalizer(ob)
+	_, err := c.ObjectbucketV1alpha1().ObjectBuckets().Update(ctx, ob, metav1.UpdateOptions{})
+	return err
+}
+
 // The OB does not have an ownerReference and must be explicitly deleted after its
 // finalizer is removed.
 // Uses Update() because Patch Strategies are not supported for CRDs
 // https://github.com/kubernetes/kubernetes/issues/50037
-func deleteObjectBucket(ob *v1alpha1.ObjectBucket, c versioned.Interface) error {
+func deleteObjectBucket(ctx context.Context, ob *v1alpha1.ObjectBucket, c versioned.Interface) error {
+	log := logr.FromContextOrDiscard(ctx)
 	// skip if ob is nil or otherwise wasn't instantiated.
 	// note: the ob is returned by Provision and Grant, partially filled
 	if ob == nil || ob.ObjectMeta.UID == "" {
 		return nil
 	}
 
-	logD.Info("removing ObjectBucket finalizer", "name", ob.Name)
+	log.V(1).Info("removing ObjectBucket finalizer", "name", ob.Name)
 	removeFinalizer(ob)
-	_, err := c.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{})
+	_, err := c.ObjectbucketV1alpha1().ObjectBuckets().Update(ctx, ob, metav1.UpdateOptions{})
 	if err != nil {
 		return err
 	}
 
-	logD.Info("deleting ObjectBucket", "name", ob.Name)
-	err = c.ObjectbucketV1alpha1().ObjectBuckets().Delete(context.TODO(), ob.Name, metav1.DeleteOptions{})
+	log.V(1).Info("deleting ObjectBucket", "name", ob.Name)
+	err = c.ObjectbucketV1alpha1().ObjectBuckets().Delete(ctx, ob.Name, metav1.DeleteOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
 			log.Error(err, "ObjectBucket vanished before we could delete it, skipping", "name", ob.Name)
@@ -269,13 +626,14 @@ func deleteObjectBucket(ob *v1alpha1.ObjectBucket, c versioned.Interface) error
 		}
 		return fmt.Errorf("error deleting ObjectBucket %q: %v", ob.Name, err)
 	}
-	logD.Info("ObjectBucket deleted", "name", ob.Name)
+	log.V(1).Info("ObjectBucket deleted", "name", ob.Name)
 	return nil
 }
 
-func updateClaim(c versioned.Interface, obc *v1alpha1.ObjectBucketClaim) (result *v1alpha1.ObjectBucketClaim, err error) {
-	logD.Info("updating", "obc", obc.Namespace+"/"+obc.Name)
-	result, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(context.TODO(), obc, metav1.UpdateOptions{})
+func updateClaim(ctx context.Context, c versioned.Interface, obc *v1alpha1.ObjectBucketClaim) (result *v1alpha1.ObjectBucketClaim, err error) {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("updating", "obc", obc.Namespace+"/"+obc.Name)
+	result, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(ctx, obc, metav1.UpdateOptions{})
 	if err != nil {
 		// return input obc here since result is nil on error returns
 		return obc, fmt.Errorf("failed to update OBC %s/%s: %v", obc.Namespace, obc.Name, err)
@@ -283,16 +641,19 @@ func updateClaim(c versioned.Interface, obc *v1alpha1.ObjectBucketClaim) (result
 	return result, err
 }
 
-func updateObjectBucketClaimPhase(c versioned.Interface, obc *v1alpha1.ObjectBucketClaim, phase v1alpha1.ObjectBucketClaimStatusPhase) (result *v1alpha1.ObjectBucketClaim, err error) {
-	logD.Info("updating status:", "obc", obc.Namespace+"/"+obc.Name, "old status",
+// updateObjectBucketClaimPhase sets the OBC's phase along with any given conditions.
+func updateObjectBucketClaimPhase(ctx context.Context, c versioned.Interface, obc *v1alpha1.ObjectBucketClaim, phase v1alpha1.ObjectBucketClaimStatusPhase, conditions ...metav1.Condition) (result *v1alpha1.ObjectBucketClaim, err error) {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("updating status:", "obc", obc.Namespace+"/"+obc.Name, "old status",
 		obc.Status.Phase, "new status", phase)
 	// Do not make changes directly to the obc used as input. If the update fails, we should return
 	// the obc given as input as it was given so code that comes after can't assume obc is at the
 	// new phase.
 	updateOBC := obc.DeepCopy()
 	updateOBC.Status.Phase = phase
+	setClaimConditions(updateOBC, conditions...)
 
-	result, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).UpdateStatus(context.TODO(), updateOBC, metav1.UpdateOptions{})
+	result, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).UpdateStatus(ctx, updateOBC, metav1.UpdateOptions{})
 	if err != nil {
 		// return input obc here since result is nil on error returns
 		return obc, fmt.Errorf("failed to update OBC %s/%s phase to %q: %v", obc.Namespace, obc.Name, phase, err)
@@ -300,15 +661,18 @@ func updateObjectBucketClaimPhase(c versioned.Interface, obc *v1alpha1.ObjectBuc
 	return result, err
 }
 
-func updateObjectBucketPhase(c versioned.Interface, ob *v1alpha1.ObjectBucket, phase v1alpha1.ObjectBucketStatusPhase) (result *v1alpha1.ObjectBucket, err error) {
-	logD.Info("updating status:", "ob", ob.Name, "old status", ob.Status.Phase, "new status", phase)
+// updateObjectBucketPhase sets the OB's phase along with any given conditions.
+func updateObjectBucketPhase(ctx context.Context, c versioned.Interface, ob *v1alpha1.ObjectBucket, phase v1alpha1.ObjectBucketStatusPhase, conditions ...metav1.Condition) (result *v1alpha1.ObjectBucket, err error) {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("updating status:", "ob", ob.Name, "old status", ob.Status.Phase, "new status", phase)
 	// Do not make changes directly to the ob used as input. If the update fails, we should return
 	// the ob given as input as it was given so code that comes after can't assume ob is at the new
 	// phase.
 	updateOB := ob.DeepCopy()
 	updateOB.Status.Phase = phase
+	setBucketConditions(updateOB, conditions...)
 
-	result, err = c.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(context.TODO(), updateOB, metav1.UpdateOptions{})
+	result, err = c.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(ctx, updateOB, metav1.UpdateOptions{})
 	if err != nil {
 		// return input ob here since result is nil on error returns
 		return ob, fmt.Errorf("failed to update OB %s phase to %q: %v", ob.Name, phase, err)
@@ -316,26 +680,75 @@ func updateObjectBucketPhase(c versioned.Interface, ob *v1alpha1.ObjectBucket, p
 	return result, err
 }
 
-// get OB from key, or nil if no OB exists
-func getObFromKey(key string, c versioned.Interface) (*v1alpha1.ObjectBucket, error) {
-	obName, err := objectBucketNameFromClaimKey(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get ob for key %q: %v", key, err)
-	}
+// updateObjectBucketClaimConditions sets the given conditions on the latest version of the OBC,
+// leaving its phase untouched. It is intended for recording failures, where the caller's copy of
+// the OBC may be stale, so the OBC is re-read and the update retried on conflicts.
+func updateObjectBucketClaimConditions(ctx context.Context, c versioned.Interface, obc *v1alpha1.ObjectBucketClaim, conditions ...metav1.Condition) error {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("updating conditions", "obc", obc.Namespace+"/"+obc.Name)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(ctx, obc.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		setClaimConditions(current, conditions...)
+		_, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).UpdateStatus(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+}
 
-	ob, err := c.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), obName, metav1.GetOptions{})
-	if err != nil {
-		// no error in this case because there is no OB which contains information, meaning we
-		// are free to provision the OBC however it is configured
-		if errors.IsNotFound(err) {
-			return nil, nil
+// failObjectBucketClaim moves the latest version of the OBC to the Failed phase and records the
+// condition describing the failure.
+func failObjectBucketClaim(ctx context.Context, c versioned.Interface, obc *v1alpha1.ObjectBucketClaim, condition metav1.Condition) error {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("updating status:", "obc", obc.Namespace+"/"+obc.Name, "new status", v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(ctx, obc.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("failed to get ob %q: %v", obName, err)
+		current.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseFailed
+		setClaimConditions(current, condition)
+		_, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).UpdateStatus(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// updateObjectBucketConditions sets the given conditions on the latest version of the OB, leaving
+// its phase untouched.
+func updateObjectBucketConditions(ctx context.Context, c versioned.Interface, ob *v1alpha1.ObjectBucket, conditions ...metav1.Condition) error {
+	log := logr.FromContextOrDiscard(ctx)
+	log.V(1).Info("updating conditions", "ob", ob.Name)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := c.ObjectbucketV1alpha1().ObjectBuckets().Get(ctx, ob.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		setBucketConditions(current, conditions...)
+		_, err = c.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// setClaimConditions records the conditions and the observed generation on the OBC's status.
+func setClaimConditions(obc *v1alpha1.ObjectBucketClaim, conditions ...metav1.Condition) {
+	obc.Status.ObservedGeneration = obc.Generation
+	for _, cond := range conditions {
+		cond.ObservedGeneration = obc.Generation
+		meta.SetStatusCondition(&obc.Status.Conditions, cond)
 	}
+}
 
-	return ob, nil
+// setBucketConditions records the conditions and the observed generation on the OB's status.
+func setBucketConditions(ob *v1alpha1.ObjectBucket, conditions ...metav1.Condition) {
+	ob.Status.ObservedGeneration = ob.Generation
+	for _, cond := range conditions {
+		cond.ObservedGeneration = ob.Generation
+		meta.SetStatusCondition(&ob.Status.Conditions, cond)
+	}
 }
 
+// get OB from key, or nil if no OB exists
 // throw error if obc config is different from the ob
 // accepts nil ob
 func errIfObcConfigHasBeenModified(ob *v1alpha1.ObjectBucket, obc *v1alpha1.ObjectBucketClaim) error {
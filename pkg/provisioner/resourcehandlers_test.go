@@ -17,14 +17,17 @@ limitations under the License.
 package provisioner
 
 import (
+	"context"
 	"strconv"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 )
@@ -59,6 +62,8 @@ func TestNewCredentialsSecret(t *testing.T) {
 	type args struct {
 		obc            *v1alpha1.ObjectBucketClaim
 		authentication *v1alpha1.Authentication
+		files          map[string]string
+		keyMapping     map[string]string
 	}
 
 	tests := []struct {
@@ -129,11 +134,127 @@ func TestNewCredentialsSecret(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "with additional secret data",
+			args: args{
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: testObjectMeta,
+				},
+				authentication: &v1alpha1.Authentication{
+					AccessKeys: &v1alpha1.AccessKeys{
+						AccessKeyID:     authKey,
+						SecretAccessKey: authSecret,
+					},
+					AdditionalSecretData: map[string]string{"SESSION_TOKEN": "token"},
+				},
+			},
+			want: &corev1.Secret{
+				ObjectMeta: testObjectMeta,
+				StringData: map[string]string{
+					v1alpha1.AwsKeyField:    authKey,
+					v1alpha1.AwsSecretField: authSecret,
+					"SESSION_TOKEN":         "token",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with additional secret data setting a reserved key",
+			args: args{
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: testObjectMeta,
+				},
+				authentication: &v1alpha1.Authentication{
+					AdditionalSecretData: map[string]string{v1alpha1.AwsSecretField: authSecret},
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "with key mapping",
+			args: args{
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: testObjectMeta,
+				},
+				authentication: &v1alpha1.Authentication{
+					AccessKeys: &v1alpha1.AccessKeys{
+						AccessKeyID:     authKey,
+						SecretAccessKey: authSecret,
+					},
+				},
+				keyMapping: map[string]string{v1alpha1.AwsKeyField: "ACCESS_KEY"},
+			},
+			want: &corev1.Secret{
+				ObjectMeta: testObjectMeta,
+				StringData: map[string]string{
+					"ACCESS_KEY":            authKey,
+					v1alpha1.AwsSecretField: authSecret,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with connection files",
+			args: args{
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: testObjectMeta,
+				},
+				authentication: &v1alpha1.Authentication{
+					AccessKeys: &v1alpha1.AccessKeys{
+						AccessKeyID:     authKey,
+						SecretAccessKey: authSecret,
+					},
+				},
+				files:      map[string]string{"credentials": "[default]\n"},
+				keyMapping: map[string]string{"credentials": "aws-credentials"},
+			},
+			want: &corev1.Secret{
+				ObjectMeta: testObjectMeta,
+				StringData: map[string]string{
+					v1alpha1.AwsKeyField:    authKey,
+					v1alpha1.AwsSecretField: authSecret,
+					"aws-credentials":       "[default]\n",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with connection file conflicting with additional secret data",
+			args: args{
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: testObjectMeta,
+				},
+				authentication: &v1alpha1.Authentication{
+					AdditionalSecretData: map[string]string{"credentials": "other"},
+				},
+				files: map[string]string{"credentials": "[default]\n"},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "with key mapping resulting in duplicate keys",
+			args: args{
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: testObjectMeta,
+				},
+				authentication: &v1alpha1.Authentication{
+					AccessKeys: &v1alpha1.AccessKeys{
+						AccessKeyID:     authKey,
+						SecretAccessKey: authSecret,
+					},
+				},
+				keyMapping: map[string]string{v1alpha1.AwsKeyField: v1alpha1.AwsSecretField},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newCredentialsSecret(tt.args.obc, tt.args.authentication, dummyLabels)
+			got, err := newCredentialsSecret(tt.args.obc, tt.args.authentication, tt.args.files, dummyLabels, tt.args.keyMapping)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewCredentailsSecret() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -145,6 +266,51 @@ func TestNewCredentialsSecret(t *testing.T) {
 	}
 }
 
+func TestCheckConnectionFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]string
+		mapping map[string]string
+		wantErr bool
+	}{
+		{
+			name: "no formats",
+		},
+		{
+			name:   "supported formats",
+			params: map[string]string{v1alpha1.StorageClassConnectionFormats: "aws-credentials, json"},
+		},
+		{
+			name:    "unsupported format",
+			params:  map[string]string{v1alpha1.StorageClassConnectionFormats: "aws-credentials,ini"},
+			wantErr: true,
+		},
+		{
+			name: "credentials mapped to a file name by the storage class",
+			params: map[string]string{
+				v1alpha1.StorageClassConnectionFormats: "json",
+				v1alpha1.StorageClassSecretKeyMapping:  "AWS_SECRET_ACCESS_KEY=connection.json",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "file mapped to a credentials key by the claim",
+			params:  map[string]string{v1alpha1.StorageClassConnectionFormats: "aws-config"},
+			mapping: map[string]string{"config": v1alpha1.AwsKeyField},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := &v1alpha1.ObjectBucketClaim{Spec: v1alpha1.ObjectBucketClaimSpec{SecretKeyMapping: tt.mapping}}
+			class := &storagev1.StorageClass{Parameters: tt.params}
+			if err := checkConnectionFormats(obc, class); (err != nil) != tt.wantErr {
+				t.Errorf("checkConnectionFormats() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestNewBucketConfigMap(t *testing.T) {
 
 	const (
@@ -154,6 +320,7 @@ func TestNewBucketConfigMap(t *testing.T) {
 		port      = 11111
 		region    = "region"
 		subRegion = "sub-region"
+		url       = "http://www.test.com:11111"
 	)
 	var isTrue = true
 
@@ -176,8 +343,9 @@ func TestNewBucketConfigMap(t *testing.T) {
 	}
 
 	type args struct {
-		ep  *v1alpha1.Endpoint
-		obc *v1alpha1.ObjectBucketClaim
+		ep         *v1alpha1.Endpoint
+		obc        *v1alpha1.ObjectBucketClaim
+		keyMapping map[string]string
 	}
 	tests := []struct {
 		name    string
@@ -208,6 +376,8 @@ func TestNewBucketConfigMap(t *testing.T) {
 					bucketName:      name,
 					bucketHost:      host,
 					bucketPort:      strconv.Itoa(port),
+					bucketURL:       url,
+					bucketScheme:    "http",
 					bucketRegion:    region,
 					bucketSubRegion: subRegion,
 				},
@@ -237,6 +407,8 @@ func TestNewBucketConfigMap(t *testing.T) {
 					bucketName:      name,
 					bucketHost:      host,
 					bucketPort:      strconv.Itoa(port),
+					bucketURL:       url,
+					bucketScheme:    "http",
 					bucketRegion:    region,
 					bucketSubRegion: "",
 				},
@@ -266,16 +438,156 @@ func TestNewBucketConfigMap(t *testing.T) {
 					bucketName:      name,
 					bucketHost:      host,
 					bucketPort:      strconv.Itoa(port),
+					bucketURL:       url,
+					bucketScheme:    "http",
 					bucketRegion:    region,
 					bucketSubRegion: subRegion,
 				},
 			},
 			wantErr: false,
 		},
+		{
+			name: "with additional config data",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost:           host,
+					BucketPort:           port,
+					BucketName:           name,
+					AdditionalConfigData: map[string]string{"PATH_STYLE": "true"},
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+			},
+			want: &corev1.ConfigMap{
+				ObjectMeta: objMeta,
+				Data: map[string]string{
+					bucketName:      name,
+					bucketHost:      host,
+					bucketPort:      strconv.Itoa(port),
+					bucketURL:       url,
+					bucketScheme:    "http",
+					bucketRegion:    "",
+					bucketSubRegion: "",
+					"PATH_STYLE":    "true",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with additional config data setting a reserved key",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost:           host,
+					BucketPort:           port,
+					BucketName:           name,
+					AdditionalConfigData: map[string]string{bucketHost: "other"},
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "with tls and addressing style",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost:      "fd00::1",
+					BucketPort:      port,
+					Scheme:          "https",
+					BucketName:      name,
+					AddressingStyle: v1alpha1.AddressingStylePath,
+					TLS: &v1alpha1.EndpointTLS{
+						InsecureSkipVerify: true,
+						CABundle:           "PEM",
+					},
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+			},
+			want: &corev1.ConfigMap{
+				ObjectMeta: objMeta,
+				Data: map[string]string{
+					bucketName:            name,
+					bucketHost:            "fd00::1",
+					bucketPort:            strconv.Itoa(port),
+					bucketURL:             "https://[fd00::1]:11111",
+					bucketScheme:          "https",
+					bucketRegion:          "",
+					bucketSubRegion:       "",
+					bucketInsecure:        "true",
+					bucketCA:              "PEM",
+					bucketAddressingStyle: "path",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with key mapping",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost: host,
+					BucketPort: port,
+					BucketName: name,
+					Region:     region,
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+				keyMapping: map[string]string{bucketURL: "AWS_ENDPOINT_URL", bucketRegion: "AWS_REGION"},
+			},
+			want: &corev1.ConfigMap{
+				ObjectMeta: objMeta,
+				Data: map[string]string{
+					bucketName:         name,
+					bucketHost:         host,
+					bucketPort:         strconv.Itoa(port),
+					"AWS_ENDPOINT_URL": url,
+					bucketScheme:       "http",
+					"AWS_REGION":       region,
+					bucketSubRegion:    "",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with unsupported addressing style",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost:      host,
+					BucketPort:      port,
+					BucketName:      name,
+					AddressingStyle: "dns",
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newBucketConfigMap(tt.args.obc, tt.args.ep, dummyLabels)
+			got, err := newBucketConfigMap(tt.args.obc, tt.args.ep, dummyLabels, tt.args.keyMapping)
 			if (err != nil) == !tt.wantErr {
 				t.Errorf("newBucketConfigMap() error = %v, wantErr %v", err, tt.wantErr)
 			} else if !cmp.Equal(tt.want, got) {
@@ -284,3 +596,28 @@ func TestNewBucketConfigMap(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveCABundle(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "ca"},
+		Data:       map[string]string{"ca.crt": "PEM"},
+	})
+	ref := &v1alpha1.CABundleReference{Namespace: "ns", Name: "ca", Key: "ca.crt"}
+	ep := &v1alpha1.Endpoint{TLS: &v1alpha1.EndpointTLS{CABundleRef: ref}}
+
+	got, err := resolveCABundle(context.TODO(), ep, client)
+	if err != nil {
+		t.Fatalf("resolveCABundle() error = %v", err)
+	}
+	if got.TLS.CABundle != "PEM" {
+		t.Errorf("resolveCABundle() CABundle = %q, want %q", got.TLS.CABundle, "PEM")
+	}
+	if ep.TLS.CABundle != "" {
+		t.Errorf("resolveCABundle() modified the given endpoint")
+	}
+
+	ep.TLS.CABundleRef = &v1alpha1.CABundleReference{Namespace: "ns", Name: "ca", Key: "missing"}
+	if _, err = resolveCABundle(context.TODO(), ep, client); err == nil {
+		t.Errorf("resolveCABundle() expected an error for a missing key")
+	}
+}
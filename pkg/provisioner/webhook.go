@@ -0,0 +1,310 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+)
+
+// webhookPath is the path under which the validating admission webhook is served. It must match
+// the path of the ValidatingWebhookConfiguration.
+const webhookPath = "/validate-objectbucketclaim"
+
+// WebhookConfig configures the validating admission webhook served with WithWebhook.
+type WebhookConfig struct {
+	// Address is the address to serve the webhook on, e.g. ":9443".
+	Address string
+	// CertFile and KeyFile are the paths of the serving certificate and key. The API server only
+	// calls webhooks over TLS.
+	CertFile string
+	KeyFile  string
+	// AllowedAdditionalConfigKeys lists the additionalConfig keys accepted in claims. Any key is
+	// accepted if empty.
+	AllowedAdditionalConfigKeys []string
+}
+
+// claimValidator validates the OBCs of a provisioner at admission time, so that users see invalid
+// claims rejected by kubectl rather than finding them stuck.
+type claimValidator struct {
+	// provisionerNames are the names of the provisioners served, in sorted order
+	provisionerNames []string
+	allowedKeys      []string
+	nameRules        api.BucketNameRules
+	// namespaces served by the provisioner, all namespaces if empty and namespaceSelector is nil
+	namespaces        []string
+	namespaceSelector labels.Selector
+	clientset         kubernetes.Interface
+	log               logr.Logger
+}
+
+func newClaimValidator(provisionerNames []string, cfg *WebhookConfig, nameRules api.BucketNameRules, namespaces []string, namespaceSelector labels.Selector, clientset kubernetes.Interface, log logr.Logger) *claimValidator {
+	allowed := append([]string(nil), cfg.AllowedAdditionalConfigKeys...)
+	sort.Strings(allowed)
+	provisionerNames = append([]string(nil), provisionerNames...)
+	sort.Strings(provisionerNames)
+	return &claimValidator{
+		provisionerNames:  provisionerNames,
+		allowedKeys:       allowed,
+		nameRules:         nameRules,
+		namespaces:        namespaces,
+		namespaceSelector: namespaceSelector,
+		clientset:         clientset,
+		log:               log.WithName("webhook"),
+	}
+}
+
+// ServeHTTP handles admission.k8s.io/v1 AdmissionReviews of OBCs.
+func (v *claimValidator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	review := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(w, "expected an AdmissionReview request", http.StatusBadRequest)
+		return
+	}
+	response := v.review(r.Context(), review.Request)
+	response.UID = review.Request.UID
+	review.Request, review.Response = nil, response
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		v.log.Error(err, "error writing admission response")
+	}
+}
+
+// review admits or denies the OBC of the request.
+func (v *claimValidator) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	log := v.log.WithValues("operation", req.Operation, "obc", req.Namespace+"/"+req.Name)
+
+	obc := &v1alpha1.ObjectBucketClaim{}
+	if err := json.Unmarshal(req.Object.Raw, obc); err != nil {
+		return deniedResponse(errors.NewBadRequest(fmt.Sprintf("error decoding ObjectBucketClaim: %v", err)))
+	}
+	if obc.Namespace == "" {
+		obc.Namespace = req.Namespace
+	}
+	var errs field.ErrorList
+	switch req.Operation {
+	case admissionv1.Create:
+		errs = v.validateCreate(ctx, obc)
+	case admissionv1.Update:
+		old := &v1alpha1.ObjectBucketClaim{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return deniedResponse(errors.NewBadRequest(fmt.Sprintf("error decoding ObjectBucketClaim: %v", err)))
+		}
+		errs = v.validateUpdate(old, obc)
+	}
+	if len(errs) > 0 {
+		log.V(1).Info("denying invalid claim", "errors", errs.ToAggregate().Error())
+		return deniedResponse(errors.NewInvalid(v1alpha1.ObjectBucketClaimGVK().GroupKind(), obc.Name, errs))
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func deniedResponse(err errors.APIStatus) *admissionv1.AdmissionResponse {
+	status := err.Status()
+	return &admissionv1.AdmissionResponse{Result: &status}
+}
+
+// validateCreate validates a new claim, including its storage class. The storage class is not
+// checked on updates, so that claims remain deletable after their storage class is gone.
+func (v *claimValidator) validateCreate(ctx context.Context, obc *v1alpha1.ObjectBucketClaim) field.ErrorList {
+	spec := field.NewPath("spec")
+	errs := v.validateAdditionalConfig(obc)
+
+	if obc.Spec.BucketName != "" && obc.Spec.GenerateBucketName != "" {
+		errs = append(errs, field.Invalid(spec.Child("bucketName"), obc.Spec.BucketName, "may not be set together with generateBucketName"))
+	}
+	if obc.Spec.BucketName != "" {
+		for _, msg := range v.nameRules.Validate(obc.Spec.BucketName) {
+			errs = append(errs, field.Invalid(spec.Child("bucketName"), obc.Spec.BucketName, msg))
+		}
+	}
+	if obc.Spec.GenerateBucketName != "" {
+		// the prefix is checked through a name generated from it
+		for _, msg := range v.nameRules.Validate(generateBucketName(obc.Spec.GenerateBucketName, v.nameRules)) {
+			errs = append(errs, field.Invalid(spec.Child("generateBucketName"), obc.Spec.GenerateBucketName, "generated names "+msg))
+		}
+	}
+
+	if obc.Spec.StorageClassName == "" {
+		return append(errs, field.Required(spec.Child("storageClassName"), ""))
+	}
+	class, err := v.clientset.StorageV1().StorageClasses().Get(ctx, obc.Spec.StorageClassName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return append(errs, field.NotFound(spec.Child("storageClassName"), obc.Spec.StorageClassName))
+	}
+	if err != nil {
+		return append(errs, field.InternalError(spec.Child("storageClassName"), err))
+	}
+	if i := sort.SearchStrings(v.provisionerNames, class.Provisioner); i == len(v.provisionerNames) || v.provisionerNames[i] != class.Provisioner {
+		errs = append(errs, field.Invalid(spec.Child("storageClassName"), obc.Spec.StorageClassName,
+			fmt.Sprintf("storage class is provisioned by %q, not by %s", class.Provisioner, v.provisionerList())))
+	} else {
+		errs = append(errs, v.validateNamespace(ctx, obc)...)
+	}
+	if err = checkConnectionFormats(obc, class); err != nil {
+		errs = append(errs, field.Invalid(spec.Child("storageClassName"), obc.Spec.StorageClassName, err.Error()))
+	}
+	if obc.Spec.BucketName == "" && obc.Spec.GenerateBucketName == "" && class.Parameters[v1alpha1.StorageClassBucket] == "" {
+		errs = append(errs, field.Required(spec.Child("bucketName"), "bucketName or generateBucketName is required unless the storage class names an existing bucket"))
+	}
+	return errs
+}
+
+// validateNamespace denies claims of the provisioner's storage classes in namespaces which the
+// provisioner does not serve, since they would never be provisioned.
+func (v *claimValidator) validateNamespace(ctx context.Context, obc *v1alpha1.ObjectBucketClaim) field.ErrorList {
+	path := field.NewPath("metadata", "namespace")
+	switch {
+	case v.namespaceSelector != nil:
+		ns, err := v.clientset.CoreV1().Namespaces().Get(ctx, obc.Namespace, metav1.GetOptions{})
+		if err != nil {
+			return field.ErrorList{field.InternalError(path, err)}
+		}
+		if !v.namespaceSelector.Matches(labels.Set(ns.Labels)) {
+			return field.ErrorList{field.Forbidden(path,
+				fmt.Sprintf("namespace does not match the selector %q of %s", v.namespaceSelector, v.provisionerList()))}
+		}
+	case len(v.namespaces) > 0:
+		for _, ns := range v.namespaces {
+			if ns == obc.Namespace {
+				return nil
+			}
+		}
+		return field.ErrorList{field.Forbidden(path, fmt.Sprintf("namespace is not served by %s", v.provisionerList()))}
+	}
+	return nil
+}
+
+// provisionerList names the provisioners in messages.
+func (v *claimValidator) provisionerList() string {
+	quoted := make([]string, 0, len(v.provisionerNames))
+	for _, name := range v.provisionerNames {
+		quoted = append(quoted, strconv.Quote(name))
+	}
+	if len(quoted) == 1 {
+		return "provisioner " + quoted[0]
+	}
+	return "provisioners " + strings.Join(quoted, ", ")
+}
+
+// validateUpdate validates a change to a claim. Like updateSupported, only the additionalConfig
+// may be changed, except for the names which the library sets once, such as spec.bucketName. A
+// claim which failed before it was bound may be changed freely, so that it can be fixed.
+func (v *claimValidator) validateUpdate(old, obc *v1alpha1.ObjectBucketClaim) field.ErrorList {
+	if obc.DeletionTimestamp != nil {
+		return nil
+	}
+	spec := field.NewPath("spec")
+	errs := v.validateAdditionalConfig(obc)
+
+	// a failed claim is retried once its spec is changed, e.g. a bucket name set in place of a
+	// rejected one
+	if old.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseFailed && old.Spec.ObjectBucketName == "" {
+		return errs
+	}
+
+	names := []struct {
+		field    string
+		old, new string
+	}{
+		{"storageClassName", old.Spec.StorageClassName, obc.Spec.StorageClassName},
+		{"bucketName", old.Spec.BucketName, obc.Spec.BucketName},
+		{"generateBucketName", old.Spec.GenerateBucketName, obc.Spec.GenerateBucketName},
+		{"objectBucketName", old.Spec.ObjectBucketName, obc.Spec.ObjectBucketName},
+		{"secretName", old.Spec.SecretName, obc.Spec.SecretName},
+		{"configMapName", old.Spec.ConfigMapName, obc.Spec.ConfigMapName},
+	}
+	for _, n := range names {
+		// the library replaces a generated bucket name which is taken before the claim is bound
+		if n.field == "bucketName" && old.Spec.GenerateBucketName != "" && old.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+			continue
+		}
+		if n.old != "" && n.new != n.old {
+			errs = append(errs, field.Invalid(spec.Child(n.field), n.new, "field is immutable"))
+		}
+	}
+	if !apiequality.Semantic.DeepEqual(old.Spec.SecretKeyMapping, obc.Spec.SecretKeyMapping) {
+		errs = append(errs, field.Forbidden(spec.Child("secretKeyMapping"), "field is immutable"))
+	}
+	if !apiequality.Semantic.DeepEqual(old.Spec.ConfigMapKeyMapping, obc.Spec.ConfigMapKeyMapping) {
+		errs = append(errs, field.Forbidden(spec.Child("configMapKeyMapping"), "field is immutable"))
+	}
+	return errs
+}
+
+// validateAdditionalConfig rejects additionalConfig keys which are not allowed by the webhook
+// configuration.
+func (v *claimValidator) validateAdditionalConfig(obc *v1alpha1.ObjectBucketClaim) field.ErrorList {
+	if len(v.allowedKeys) == 0 {
+		return nil
+	}
+	var errs field.ErrorList
+	path := field.NewPath("spec", "additionalConfig")
+	keys := make([]string, 0, len(obc.Spec.AdditionalConfig))
+	for key := range obc.Spec.AdditionalConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		i := sort.SearchStrings(v.allowedKeys, key)
+		if i == len(v.allowedKeys) || v.allowedKeys[i] != key {
+			errs = append(errs, field.NotSupported(path.Key(key), key, v.allowedKeys))
+		}
+	}
+	return errs
+}
+
+// serveWebhook serves the validating admission webhook over TLS until ctx is done.
+func serveWebhook(ctx context.Context, log logr.Logger, cfg *WebhookConfig, validator http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle(webhookPath, validator)
+	server := &http.Server{Addr: cfg.Address, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error(err, "error shutting down webhook server")
+		}
+	}()
+
+	log.Info("serving webhook", "address", cfg.Address, "path", webhookPath)
+	if err := server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile); err != nil && err != http.ErrServerClosed {
+		log.Error(err, "webhook server failed")
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+)
+
+// unnormalizedRules are the S3 bucket name rules without normalization of prefixes.
+type unnormalizedRules struct {
+	api.S3BucketNameRules
+}
+
+func (unnormalizedRules) NormalizePrefix(prefix string) string {
+	return prefix
+}
+
+func Test_claimValidator_review(t *testing.T) {
+	otherClass := newTestStorageClass()
+	otherClass.Name = "other-class"
+	otherClass.Provisioner = "other.io/bucket"
+	existingClass := newTestStorageClass()
+	existingClass.Name = "existing-bucket-class"
+	existingClass.Parameters = map[string]string{v1alpha1.StorageClassBucket: "existing"}
+	formatsClass := newTestStorageClass()
+	formatsClass.Name = "formats-class"
+	formatsClass.Parameters = map[string]string{
+		v1alpha1.StorageClassConnectionFormats: "aws-credentials",
+		v1alpha1.StorageClassSecretKeyMapping:  "AWS_ACCESS_KEY_ID=credentials",
+	}
+
+	bound := newTestClaim()
+	bound.Spec.BucketName = "test-bucket-1234"
+	bound.Spec.SecretName = testName
+	bound.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+	pending := bound.DeepCopy()
+	pending.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhasePending
+	failed := bound.DeepCopy()
+	failed.Spec.GenerateBucketName = ""
+	failed.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseFailed
+
+	tests := []struct {
+		name         string
+		operation    admissionv1.Operation
+		old          *v1alpha1.ObjectBucketClaim
+		mutate       func(obc *v1alpha1.ObjectBucketClaim)
+		allowed      []string
+		provisioners []string
+		// rules default to api.S3BucketNameRules
+		rules      api.BucketNameRules
+		namespaces []string
+		selector   labels.Selector
+		want       []string
+	}{
+		{
+			name:      "valid claim",
+			operation: admissionv1.Create,
+		},
+		{
+			name:       "served namespace",
+			operation:  admissionv1.Create,
+			namespaces: []string{"other-namespace", testNamespace},
+		},
+		{
+			name:       "namespace not served",
+			operation:  admissionv1.Create,
+			namespaces: []string{"other-namespace"},
+			want:       []string{"metadata.namespace: Forbidden", "not served"},
+		},
+		{
+			name:      "namespace matching the selector",
+			operation: admissionv1.Create,
+			selector:  labels.SelectorFromSet(labels.Set{"bucket-provisioning": "enabled"}),
+		},
+		{
+			name:      "namespace not matching the selector",
+			operation: admissionv1.Create,
+			selector:  labels.SelectorFromSet(labels.Set{"bucket-provisioning": "disabled"}),
+			want:      []string{"metadata.namespace: Forbidden", "bucket-provisioning=disabled"},
+		},
+		{
+			name:      "both bucketName and generateBucketName",
+			operation: admissionv1.Create,
+			mutate:    func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.BucketName = "bucket" },
+			want:      []string{"spec.bucketName", "may not be set together with generateBucketName"},
+		},
+		{
+			name:      "neither bucketName nor generateBucketName",
+			operation: admissionv1.Create,
+			mutate:    func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.GenerateBucketName = "" },
+			want:      []string{"spec.bucketName: Required value"},
+		},
+		{
+			name:      "neither bucketName nor generateBucketName with existing bucket",
+			operation: admissionv1.Create,
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) {
+				obc.Spec.GenerateBucketName = ""
+				obc.Spec.StorageClassName = existingClass.Name
+			},
+		},
+		{
+			name:      "invalid bucket name",
+			operation: admissionv1.Create,
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) {
+				obc.Spec.GenerateBucketName = ""
+				obc.Spec.BucketName = "My_Bucket"
+			},
+			want: []string{"spec.bucketName", "lowercase letters"},
+		},
+		{
+			name:      "invalid bucket name prefix",
+			operation: admissionv1.Create,
+			rules:     unnormalizedRules{},
+			mutate:    func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.GenerateBucketName = "xn--photos" },
+			want:      []string{"spec.generateBucketName", `must not start with "xn--"`},
+		},
+		{
+			name:      "bucket name prefix fixed by normalization",
+			operation: admissionv1.Create,
+			mutate:    func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.GenerateBucketName = "xn--photos" },
+		},
+		{
+			name:      "unknown storage class",
+			operation: admissionv1.Create,
+			mutate:    func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.StorageClassName = "missing" },
+			want:      []string{"spec.storageClassName: Not found"},
+		},
+		{
+			name:      "storage class of another provisioner",
+			operation: admissionv1.Create,
+			mutate:    func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.StorageClassName = otherClass.Name },
+			want:      []string{"spec.storageClassName", `provisioned by "other.io/bucket"`},
+		},
+		{
+			name:         "storage class of another served provisioner",
+			operation:    admissionv1.Create,
+			provisioners: []string{otherClass.Provisioner, provisionerName},
+			mutate:       func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.StorageClassName = otherClass.Name },
+		},
+		{
+			name:      "conflicting connection formats",
+			operation: admissionv1.Create,
+			mutate:    func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.StorageClassName = formatsClass.Name },
+			want:      []string{"spec.storageClassName", `conflicts with Secret key "credentials"`},
+		},
+		{
+			name:      "disallowed additionalConfig key",
+			operation: admissionv1.Create,
+			allowed:   []string{"maxObjects"},
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) {
+				obc.Spec.AdditionalConfig = map[string]string{"maxObjects": "10", "owner": "me"}
+			},
+			want: []string{"spec.additionalConfig[owner]: Unsupported value"},
+		},
+		{
+			name:      "additionalConfig update",
+			operation: admissionv1.Update,
+			old:       bound,
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) {
+				obc.Spec.AdditionalConfig = map[string]string{"maxObjects": "10"}
+			},
+		},
+		{
+			name:      "names set by the library",
+			operation: admissionv1.Update,
+			old:       newTestClaim(),
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) {
+				obc.Spec.BucketName = "test-bucket-1234"
+				obc.Spec.ObjectBucketName = "obc-" + testNamespace + "-" + testName
+			},
+		},
+		{
+			name:      "regenerated bucket name",
+			operation: admissionv1.Update,
+			old:       pending,
+			mutate:    func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.BucketName = "test-bucket-5678" },
+		},
+		{
+			name:      "explicit bucket name of pending claim",
+			operation: admissionv1.Update,
+			old: func() *v1alpha1.ObjectBucketClaim {
+				obc := pending.DeepCopy()
+				obc.Spec.GenerateBucketName = ""
+				return obc
+			}(),
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.BucketName = "other-bucket" },
+			want:   []string{"spec.bucketName", "field is immutable"},
+		},
+		{
+			name:      "fix of a failed claim",
+			operation: admissionv1.Update,
+			old:       failed,
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) {
+				obc.Spec.BucketName = "other-bucket"
+				obc.Spec.SecretName = "other-secret"
+				obc.Spec.SecretKeyMapping = map[string]string{v1alpha1.AwsKeyField: "ACCESS_KEY"}
+			},
+		},
+		{
+			name:      "failed claim which was bound",
+			operation: admissionv1.Update,
+			old: func() *v1alpha1.ObjectBucketClaim {
+				obc := failed.DeepCopy()
+				obc.Spec.ObjectBucketName = "obc-" + testNamespace + "-" + testName
+				return obc
+			}(),
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) { obc.Spec.BucketName = "other-bucket" },
+			want:   []string{"spec.bucketName", "field is immutable"},
+		},
+		{
+			name:      "immutable fields",
+			operation: admissionv1.Update,
+			old:       bound,
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) {
+				obc.Spec.BucketName = "other-bucket"
+				obc.Spec.SecretName = "other-secret"
+				obc.Spec.SecretKeyMapping = map[string]string{v1alpha1.AwsKeyField: "ACCESS_KEY"}
+			},
+			want: []string{"spec.bucketName", "spec.secretName", "spec.secretKeyMapping", "field is immutable"},
+		},
+		{
+			name:      "deleted claim",
+			operation: admissionv1.Update,
+			old:       bound,
+			mutate: func(obc *v1alpha1.ObjectBucketClaim) {
+				now := metav1.Now()
+				obc.DeletionTimestamp = &now
+				obc.Spec.StorageClassName = "missing"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace, Labels: map[string]string{"bucket-provisioning": "enabled"}}}
+			client := fake.NewSimpleClientset(newTestStorageClass(), otherClass, existingClass, formatsClass, namespace)
+			provisioners := tt.provisioners
+			if provisioners == nil {
+				provisioners = []string{provisionerName}
+			}
+			rules := tt.rules
+			if rules == nil {
+				rules = api.S3BucketNameRules{}
+			}
+			v := newClaimValidator(provisioners, &WebhookConfig{AllowedAdditionalConfigKeys: tt.allowed}, rules, tt.namespaces, tt.selector, client, logr.Discard())
+
+			obc := newTestClaim()
+			if tt.old != nil {
+				obc = tt.old.DeepCopy()
+			}
+			if tt.mutate != nil {
+				tt.mutate(obc)
+			}
+			req := &admissionv1.AdmissionRequest{
+				Operation: tt.operation,
+				Object:    runtime.RawExtension{Raw: mustMarshal(t, obc)},
+			}
+			if tt.old != nil {
+				req.OldObject = runtime.RawExtension{Raw: mustMarshal(t, tt.old)}
+			}
+
+			got := v.review(context.TODO(), req)
+			if got.Allowed != (len(tt.want) == 0) {
+				t.Fatalf("review() allowed = %v, result %v", got.Allowed, got.Result)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got.Result.Message, want) {
+					t.Errorf("review() message %q does not contain %q", got.Result.Message, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_claimValidator_ServeHTTP(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestStorageClass())
+	v := newClaimValidator([]string{provisionerName}, &WebhookConfig{}, api.S3BucketNameRules{}, nil, nil, client, logr.Discard())
+	obc := newTestClaim()
+	obc.Spec.StorageClassName = "missing"
+
+	review := &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("1234"),
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: mustMarshal(t, obc)},
+		},
+	}
+	rec := httptest.NewRecorder()
+	v.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, webhookPath, bytes.NewReader(mustMarshal(t, review))))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	got := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), got); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if got.Response == nil || got.Response.UID != "1234" || got.Response.Allowed {
+		t.Errorf("unexpected response %+v", got.Response)
+	}
+	if got.Kind != "AdmissionReview" || got.Request != nil {
+		t.Errorf("unexpected review %+v", got)
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("error marshaling %T: %v", v, err)
+	}
+	return b
+}